@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// bulkAction runs an action of the bulk menu on the hosts selected in the
+// list. The changes of an action are made after one backup, in one
+// transaction and one write of the ssh config.
+func (s *AllConfigs) bulkAction(hosts []string) error {
+	items := []string{
+		"Move to folder",
+		"Remove",
+		"Set http proxy",
+		"Set user",
+		"Set password",
+		"ping all",
+		"Export",
+		goback,
+	}
+
+	message := fmt.Sprintf("%d hosts selected: %s\n\n", len(hosts), strings.Join(hosts, ", "))
+	command, err := main_ui(items, message, false)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case goback:
+		return errGoBack
+
+	case "Move to folder":
+		folder, _, err := s.pickFolder("", false)
+		if err != nil {
+			return err
+		}
+		doConfigBackup("all")
+		return updateProfilesFolder(hosts, folder)
+
+	case "Remove":
+		answer, err := main_ui([]string{"Yes", "No"}, fmt.Sprintf("Remove %d profile(s): %s?\n\n", len(hosts), strings.Join(hosts, ", ")), false)
+		if err != nil || answer != "Yes" {
+			return errGoBack
+		}
+
+		doConfigBackup("all")
+		if err := s.removefromDatabase(hosts...); err != nil {
+			return fmt.Errorf("failed to remove the profiles from database: %w", err)
+		}
+		for _, host := range hosts {
+			s.removeItemFromStruct(host)
+		}
+		if err := s.pushConfigToFile(); err != nil {
+			return fmt.Errorf("error removing the profiles: %w", err)
+		}
+
+	case "Set http proxy":
+		proxy, err := readProxy()
+		if err != nil {
+			return err
+		}
+
+		doConfigBackup("all")
+		return s.updateHosts(hosts, func(h *SSHConfig) { h.Proxy = proxy })
+
+	case "Set user":
+		var user string
+		fmt.Print("User: ")
+		scanner := bufio.NewScanner(os.Stdin)
+		if scanner.Scan() {
+			user = strings.TrimSpace(scanner.Text())
+		}
+		if len(user) == 0 || strings.ContainsAny(user, " \t") {
+			return fmt.Errorf("invalid user name %q", user)
+		}
+
+		doConfigBackup("all")
+		return s.updateHosts(hosts, func(h *SSHConfig) { h.User = user })
+
+	case "Set password":
+		fmt.Print("\nEnter password: ")
+		bytePassword, err := term.ReadPassword(uintptr(syscall.Stdin))
+		fmt.Println()
+		if err != nil {
+			return fmt.Errorf("error reading password: %w", err)
+		}
+
+		doConfigBackup("all")
+		if err := encryptAndPushToDBForHosts(hosts, "password", string(bytePassword)); err != nil {
+			return fmt.Errorf("failed to push the password to db: %w", err)
+		}
+
+	case "ping all":
+		s.pingAll(hosts)
+		pressEnter()
+
+	case "Export":
+		if err := s.exportHosts(hosts); err != nil {
+			return err
+		}
+		pressEnter()
+	}
+
+	return nil
+}
+
+// updateHosts changes the hosts in the ssh config and writes it once.
+func (s *AllConfigs) updateHosts(hosts []string, change func(h *SSHConfig)) error {
+	for _, host := range hosts {
+		h := s.extractHost(host)
+		if h == nil {
+			return fmt.Errorf("error extracting host: %s", host)
+		}
+		change(h)
+	}
+
+	if err := s.pushConfigToFile(); err != nil {
+		return fmt.Errorf("error adding/updating profile: %w", err)
+	}
+	return nil
+}
+
+// updateProfilesFolder moves the hosts to the folder in one transaction,
+// "NULL" takes them out of their folder.
+func updateProfilesFolder(hosts []string, folder string) error {
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the db transaction for folder update:%v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO sshprofiles(host,folder) VALUES(?,?) ON CONFLICT(host) DO UPDATE SET folder = excluded.folder;")
+	if err != nil {
+		return fmt.Errorf("failed to prepare the db for folder update:%v", err)
+	}
+	defer stmt.Close()
+
+	value := sql.NullString{String: folder, Valid: folder != "NULL"}
+	for _, host := range hosts {
+		if _, err := stmt.Exec(host, value); err != nil {
+			return fmt.Errorf("failed to update the folder for the host %v: %v", host, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// pingAll pings the hosts at the same time and lists which ones answered.
+func (s *AllConfigs) pingAll(hosts []string) {
+	if err := checkShellCommands("ping"); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	count := "-c"
+	if runtime.GOOS == "windows" {
+		count = "-n"
+	}
+
+	results := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		h := s.extractHost(host)
+		if h == nil || h.HostName == "" {
+			results[i] = fmt.Errorf("no HostName")
+			continue
+		}
+
+		wg.Go(func() {
+			results[i] = exec.Command("ping", count, "2", h.HostName).Run()
+		})
+	}
+
+	fmt.Printf("Pinging %d hosts...\n\n", len(hosts))
+	wg.Wait()
+
+	for i, host := range hosts {
+		if results[i] != nil {
+			fmt.Printf("%s✗%s %s (%v)\n", red, reset, host, results[i])
+		} else {
+			fmt.Printf("%s✓%s %s\n", green, reset, host)
+		}
+	}
+}
+
+// exportHosts writes the config blocks of the hosts to a new file.
+func (s *AllConfigs) exportHosts(hosts []string) error {
+	path := "sshcli_export.config"
+	fmt.Printf("Export to (%s): ", path)
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() && strings.TrimSpace(scanner.Text()) != "" {
+		path = strings.TrimSpace(scanner.Text())
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create the export file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, host := range hosts {
+		if h := s.extractHost(host); h != nil {
+			for _, line := range h.configBlock() {
+				fmt.Fprintln(writer, line)
+			}
+			fmt.Fprintln(writer)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write the export file: %w", err)
+	}
+
+	fmt.Printf("%d profiles exported to %s\n", len(hosts), path)
+	return nil
+}
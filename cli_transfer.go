@@ -0,0 +1,246 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+)
+
+const transferUsage = `Usage:
+  sshcli get [-r] HOST:REMOTE_PATH... LOCAL_PATH
+  sshcli put [-r] LOCAL_PATH... HOST:REMOTE_PATH
+
+HOST is a profile of the ssh config, its stored password and passphrase are used.
+Paths may contain glob patterns, quote them so the local shell leaves remote ones alone.`
+
+// runTransferCli runs the get and put subcommands and returns the exit code:
+// 0 when everything was copied, 1 when a transfer failed and 2 on usage errors.
+func runTransferCli(args []string) int {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	recursive := flags.Bool("r", false, "copy folders recursively")
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, transferUsage) }
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) < 2 {
+		flags.Usage()
+		return 2
+	}
+	sources, dest := paths[:len(paths)-1], paths[len(paths)-1]
+
+	// The remote side is the one written as HOST:PATH
+	var hostId string
+	remoteArgs := sources
+	if args[0] == "put" {
+		remoteArgs = []string{dest}
+	}
+	for i, p := range remoteArgs {
+		h, rp, ok := strings.Cut(p, ":")
+		if !ok || h == "" {
+			fmt.Fprintf(os.Stderr, "sshcli: %s is not in the HOST:PATH form\n", p)
+			return 2
+		}
+		if hostId != "" && h != hostId {
+			fmt.Fprintln(os.Stderr, "sshcli: all the remote paths must be on the same host")
+			return 2
+		}
+		hostId = h
+		remoteArgs[i] = rp
+	}
+	if args[0] == "put" {
+		dest = remoteArgs[0]
+	}
+
+	h, err := loadProfile(hostId)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sshcli: %v\n", err)
+		return 1
+	}
+
+	sftpClient, sshClient, err := dialProfile(h)
+	if err != nil {
+		log.Printf("failed to connect to %s: %v", hostId, err)
+		fmt.Fprintf(os.Stderr, "sshcli: failed to connect to %s: %v\n", hostId, err)
+		if sshClient != nil {
+			sshClient.Close()
+		}
+		return 1
+	}
+	defer sshClient.Close()
+	defer sftpClient.Close()
+
+	localFS := NewFileSystem(false, nil, nil)
+	remoteFS := NewFileSystem(true, sftpClient, sshClient)
+	remoteFS.hostId = hostId
+	if wd, err := os.Getwd(); err == nil {
+		localFS.currentPath = wd
+	}
+	if wd, err := sftpClient.Getwd(); err == nil {
+		remoteFS.currentPath = wd
+		remoteFS.homeDir = wd
+	}
+
+	sourceFS, targetFS := remoteFS, localFS
+	if args[0] == "put" {
+		sourceFS, targetFS = localFS, remoteFS
+	}
+
+	var matches []string
+	for _, p := range sources {
+		p = sourceFS.resolvePath(p)
+		found, err := sourceFS.glob(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sshcli: %s: %v\n", p, err)
+			return 2
+		}
+		if len(found) == 0 {
+			fmt.Fprintf(os.Stderr, "sshcli: %s: no such file or folder\n", p)
+			return 1
+		}
+		matches = append(matches, found...)
+	}
+
+	dest = targetFS.resolvePath(dest)
+	destIsDir := false
+	if info, err := targetFS.stat(dest); err == nil && info.IsDir() {
+		destIsDir = true
+	}
+	if len(matches) > 1 && !destIsDir {
+		fmt.Fprintf(os.Stderr, "sshcli: %s must be an existing folder to copy several files into\n", dest)
+		return 2
+	}
+
+	showProgress := term.IsTerminal(os.Stdout.Fd())
+
+	code := 0
+	for _, source := range matches {
+		target := dest
+		if destIsDir {
+			target = filepath.Join(dest, filepath.Base(source))
+		}
+
+		if err := copyTree(sourceFS, targetFS, source, target, *recursive, showProgress); err != nil {
+			log.Printf("%s of %s failed: %v", args[0], source, err)
+			fmt.Fprintf(os.Stderr, "sshcli: %v\n", err)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+func (fs *FileSystem) glob(pattern string) ([]string, error) {
+	if fs.isRemote {
+		sftpClient, _ := fs.clients()
+		return sftpClient.Glob(pattern)
+	}
+	return filepath.Glob(pattern)
+}
+
+// copyTree copies sourcePath to targetPath. Folders are only copied when
+// recursive is set, links inside them are skipped.
+func copyTree(sourceFS, targetFS *FileSystem, sourcePath, targetPath string, recursive, showProgress bool) error {
+	info, err := sourceFS.stat(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", sourcePath, err)
+	}
+
+	if !info.IsDir() {
+		return copyFileCli(sourceFS, targetFS, sourcePath, targetPath, showProgress)
+	}
+
+	if !recursive {
+		return fmt.Errorf("%s is a folder, use -r to copy folders", sourcePath)
+	}
+
+	if err := targetFS.mkdirAll(targetPath); err != nil {
+		return fmt.Errorf("failed to create %s: %w", targetPath, err)
+	}
+
+	entries, err := sourceFS.readDir(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", sourcePath, err)
+	}
+
+	var failed error
+	for _, entry := range entries {
+		if entry.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		if err := copyTree(sourceFS, targetFS, filepath.Join(sourcePath, entry.Name()), filepath.Join(targetPath, entry.Name()), true, showProgress); err != nil {
+			fmt.Fprintf(os.Stderr, "sshcli: %v\n", err)
+			failed = fmt.Errorf("some files of %s were not copied", sourcePath)
+		}
+	}
+
+	return failed
+}
+
+// copyFileCli copies a single file, drawing its progress on the terminal.
+func copyFileCli(sourceFS, targetFS *FileSystem, sourcePath, targetPath string, showProgress bool) error {
+	start := time.Now()
+	var size int64
+	if info, err := sourceFS.stat(sourcePath); err == nil {
+		size = info.Size()
+	}
+
+	if !showProgress {
+		checksum, err := streamTransfer(sourceFS, targetFS, sourcePath, targetPath, new(transferProgress))
+		logCliTransfer(sourceFS, targetFS, sourcePath, targetPath, size, start, checksum, err)
+		return err
+	}
+
+	var currentProgress transferProgress
+	name := filepath.Base(sourcePath)
+
+	draw := func() {
+		progress := strings.Split(currentProgress.get(), "^")
+		if len(progress) < 3 {
+			return
+		}
+		fmt.Printf("\r%-40s %5s %10s %12s", name, progress[0], progress[1], progress[2])
+	}
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				draw()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	checksum, err := streamTransfer(sourceFS, targetFS, sourcePath, targetPath, &currentProgress)
+	ticker.Stop()
+	close(done)
+	logCliTransfer(sourceFS, targetFS, sourcePath, targetPath, size, start, checksum, err)
+
+	if err == nil {
+		draw()
+		fmt.Println()
+	} else if currentProgress.get() != "" {
+		fmt.Println()
+	}
+
+	return err
+}
+
+func logCliTransfer(sourceFS, targetFS *FileSystem, sourcePath, targetPath string, size int64, start time.Time, checksum string, err error) {
+	result := "ok"
+	if err != nil {
+		result = fmt.Sprintf("failed: %v", err)
+	}
+	logTransfer(sourceFS, targetFS, sourcePath, targetPath, size, start, checksum, result)
+}
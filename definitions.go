@@ -71,7 +71,7 @@ type (
 	baseModel struct {
 		allChoices   []string
 		choices      []string
-		selected     map[int]string
+		selected     map[string]bool
 		cursor       int
 		choice       string
 		searchQuery  string
@@ -79,6 +79,22 @@ type (
 		inSearchMode bool
 		isSSHContext bool
 		lastClick    time.Time
+		// matches tells how each choice matched the search, for the highlighting
+		matches map[string]searchMatch
+		// searchAll also searches the notes and settings, and the hosts of all folders
+		searchAll bool
+		// maskWidth pads the aliases in secure mode
+		maskWidth int
+		// height is the height of the terminal, offset the first choice shown
+		height int
+		offset int
+		// preview shows the host under the cursor, when the list has hosts
+		preview  bool
+		hasHosts bool
+		// bulk asks for the bulk action menu on the selected hosts
+		bulk bool
+		// hostList is the main list of InitUi, its searches can be saved
+		hostList bool
 	}
 	main_model struct {
 		baseModel
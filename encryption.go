@@ -10,28 +10,29 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"slices"
 	"strings"
 )
 
 func encryptAndPushToDB(hostname, column, password string) error {
+	return encryptAndPushToDBForHosts([]string{hostname}, column, password)
+}
+
+// encryptAndPushToDBForHosts stores the same secret for all the hosts in one transaction.
+func encryptAndPushToDBForHosts(hostnames []string, column, password string) error {
 	if len(password) == 0 {
 		return fmt.Errorf("%s is empty", column)
 	}
 
-	if len(hostname) == 0 {
+	if len(hostnames) == 0 || slices.Contains(hostnames, "") {
 		return fmt.Errorf("host is empty")
 	}
 
-	encryptedString, err := encrypt([]byte(password))
-	if err != nil {
-		err = fmt.Errorf("error encrypting %s: %v", column, err)
-		return err
-	}
-
 	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin the db transaction for %s insertion:%w", column, err)
 	}
+	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO sshprofiles(host,%s) VALUES(?,?) ON CONFLICT(host) DO UPDATE SET %s = excluded.%s;", column, column, column))
 	if err != nil {
@@ -39,9 +40,17 @@ func encryptAndPushToDB(hostname, column, password string) error {
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(hostname, encryptedString)
-	if err != nil {
-		return fmt.Errorf("failed to insert the %s for the host %v: %w", column, hostname, err)
+	for _, hostname := range hostnames {
+		encryptedString, err := encrypt([]byte(password))
+		if err != nil {
+			err = fmt.Errorf("error encrypting %s: %v", column, err)
+			return err
+		}
+
+		_, err = stmt.Exec(hostname, encryptedString)
+		if err != nil {
+			return fmt.Errorf("failed to insert the %s for the host %v: %w", column, hostname, err)
+		}
 	}
 
 	log.Printf("%s has been successfully added to the database!\n", column)
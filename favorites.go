@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+)
+
+// The main list starts with the favorite hosts, pinned with f, and the hosts
+// connected to last, from every folder.
+
+const (
+	favoritesHeader = "★ Favorites"
+	recentHeader    = "🕘 Recent"
+
+	// toggleFavoritePrefix starts the choice the host list returns when the
+	// host of the line that follows it is to be (un)pinned.
+	toggleFavoritePrefix = "☆ "
+)
+
+// toggleFavorite pins the host to the favorites, or unpins it.
+func toggleFavorite(host string) error {
+	if host == "" {
+		return fmt.Errorf("only hosts can be favorites")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the db transaction for the favorites:%w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO sshprofiles(host,favorite) VALUES(?,1) ON CONFLICT(host) DO UPDATE SET favorite = NOT coalesce(favorite, 0);")
+	if err != nil {
+		return fmt.Errorf("failed to prepare the favorites statement: %w", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(host); err != nil {
+		return fmt.Errorf("failed to (un)pin %s: %w", host, err)
+	}
+
+	return tx.Commit()
+}
+
+// readFavorites returns the favorite hosts, sorted by name.
+func readFavorites() ([]string, error) {
+	return readHostColumn("SELECT host FROM sshprofiles WHERE favorite = 1 ORDER BY host")
+}
+
+// readRecent returns the hosts that aren't favorites, the last connected first.
+func readRecent() ([]string, error) {
+	return readHostColumn("SELECT host FROM sshprofiles WHERE last_connected IS NOT NULL AND coalesce(favorite, 0) = 0 ORDER BY last_connected DESC")
+}
+
+func readHostColumn(query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying the hosts: %w", err)
+	}
+	defer rows.Close()
+
+	var hosts []string
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return hosts, fmt.Errorf("error scanning the hosts: %w", err)
+		}
+		hosts = append(hosts, host)
+	}
+
+	return hosts, rows.Err()
+}
+
+// recentCount is the number of hosts the Recent section shows.
+func recentCount() int {
+	count, err := strconv.Atoi(readSetting("recent_hosts"))
+	if err != nil {
+		log.Printf("the setting recent_hosts is not a number: %v", err)
+		count, _ = strconv.Atoi(settingDefaults["recent_hosts"])
+	}
+	return count
+}
+
+// pinnedSections returns the Favorites and Recent sections of the main list.
+// rootLines are the lines of the hosts outside the folders, the lines of the
+// other hosts come from their folder.
+func (s *AllConfigs) pinnedSections(rootLines []string, folderhost map[string]string) []string {
+	favorites, err := readFavorites()
+	if err != nil {
+		log.Println(err)
+	}
+	recent, err := readRecent()
+	if err != nil {
+		log.Println(err)
+	}
+	if len(favorites) == 0 && len(recent) == 0 {
+		return nil
+	}
+
+	lines := map[string]string{}
+	for _, line := range rootLines {
+		lines[hostAlias(line)] = line
+	}
+
+	var folders []string
+	for _, host := range append(slices.Clone(favorites), recent...) {
+		if f := folderhost[host]; f != "" && f != "NULL" && !slices.Contains(folders, f) {
+			folders = append(folders, f)
+		}
+	}
+	for _, f := range folders {
+		for _, line := range s.getItems(f) {
+			if alias := hostAlias(line); alias != "" {
+				lines[alias] = line
+			}
+		}
+	}
+
+	var sections []string
+	section := func(header string, hosts []string, limit int) {
+		var found []string
+		for _, host := range hosts {
+			if line, ok := lines[host]; ok && len(found) < limit {
+				found = append(found, line)
+			}
+		}
+		if len(found) > 0 {
+			sections = append(sections, header)
+			sections = append(sections, found...)
+		}
+	}
+	section(favoritesHeader, favorites, len(favorites))
+	section(recentHeader, recent, recentCount())
+
+	return sections
+}
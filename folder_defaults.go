@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// folderDefaultsItem is the entry of a folder's list that opens its defaults.
+const folderDefaultsItem = "🔧 Folder defaults"
+
+// folderDefaultKeys are the settings a folder can give to its hosts. The
+// hosts of the folder and its subfolders inherit the ones their profile
+// leaves out, a subfolder's own default wins over its parent's.
+var folderDefaultKeys = []string{"User", "Port", "IdentityFile", "ProxyCommand", "ProxyJump", "Password"}
+
+// readFolderDefaults returns the defaults set on the folder itself, the
+// password decrypted.
+func readFolderDefaults(folder string) (map[string]string, error) {
+	defaults := map[string]string{}
+
+	rows, err := db.Query("SELECT key, value FROM folder_defaults WHERE folder = ?", folder)
+	if err != nil {
+		return defaults, fmt.Errorf("error querying the defaults of %s: %w", folder, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value sql.NullString
+		if err := rows.Scan(&key, &value); err != nil {
+			return defaults, fmt.Errorf("error scanning the defaults of %s: %w", folder, err)
+		}
+		if !value.Valid || value.String == "" {
+			continue
+		}
+
+		if key == "Password" {
+			if value.String, err = decrypt(value.String); err != nil {
+				log.Printf("failed to decrypt the default password of %s: %v", folder, err)
+				continue
+			}
+		}
+		defaults[key] = value.String
+	}
+
+	return defaults, rows.Err()
+}
+
+// writeFolderDefault sets a default of the folder, an empty value removes it.
+func writeFolderDefault(folder, key, value string) error {
+	if !slices.Contains(folderDefaultKeys, key) {
+		return fmt.Errorf("unknown folder default %s", key)
+	}
+
+	if key == "Password" && value != "" {
+		encrypted, err := encrypt([]byte(value))
+		if err != nil {
+			return fmt.Errorf("error encrypting the default password: %v", err)
+		}
+		value = encrypted
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the db transaction for the folder defaults:%w", err)
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO folder_defaults(folder,key,value) VALUES(?,?,?) ON CONFLICT(folder,key) DO UPDATE SET value = excluded.value;"
+	args := []any{folder, key, value}
+	if value == "" {
+		query = "DELETE FROM folder_defaults WHERE folder = ? AND key = ?;"
+		args = args[:2]
+	}
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare the folder defaults statement: %w", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(args...); err != nil {
+		return fmt.Errorf("failed to store the %s default of %s: %w", key, folder, err)
+	}
+
+	return tx.Commit()
+}
+
+// inheritedDefaults returns the defaults a host of the folder gets, and the
+// folder each of them comes from.
+func inheritedDefaults(folder string) (map[string]string, map[string]string) {
+	values, from := map[string]string{}, map[string]string{}
+
+	for f := folder; f != ""; f = parentFolder(f) {
+		defaults, err := readFolderDefaults(f)
+		if err != nil {
+			log.Println(err)
+		}
+		for key, value := range defaults {
+			if _, ok := values[key]; !ok {
+				values[key], from[key] = value, f
+			}
+		}
+	}
+
+	return values, from
+}
+
+// withFolderDefaults returns a copy of the host with what its profile leaves
+// out filled from its folder, and the folder each inherited value comes from.
+// The host itself is left alone, so the defaults never end up in the ssh
+// config by accident.
+func (s *AllConfigs) withFolderDefaults(h *SSHConfig) (*SSHConfig, map[string]string) {
+	effective := *h
+	effective.OtherAttribs = slices.Clone(h.OtherAttribs)
+	inherited := map[string]string{}
+	if h.Folder == "" {
+		return &effective, inherited
+	}
+
+	values, from := inheritedDefaults(h.Folder)
+
+	hasJump := slices.ContainsFunc(h.OtherAttribs, func(a string) bool {
+		return strings.HasPrefix(strings.ToLower(strings.TrimSpace(a)), "proxyjump")
+	})
+
+	for _, key := range folderDefaultKeys {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+
+		var field *string
+		switch key {
+		case "User":
+			field = &effective.User
+		case "Port":
+			field = &effective.Port
+		case "IdentityFile":
+			field = &effective.IdentityFile
+		case "Password":
+			field = &effective.Password
+		case "ProxyCommand":
+			if hasJump {
+				continue
+			}
+			field = &effective.Proxy
+		case "ProxyJump":
+			if hasJump || effective.Proxy != "" {
+				continue
+			}
+			effective.OtherAttribs = append(effective.OtherAttribs, "ProxyJump "+value)
+			inherited[key] = from[key]
+			continue
+		}
+
+		if *field == "" {
+			*field = value
+			inherited[key] = from[key]
+		}
+	}
+
+	return &effective, inherited
+}
+
+// defaultValue returns the value of one of the folderDefaultKeys in the host.
+func defaultValue(h *SSHConfig, key string) string {
+	switch key {
+	case "User":
+		return h.User
+	case "Port":
+		return h.Port
+	case "IdentityFile":
+		return h.IdentityFile
+	case "ProxyCommand":
+		return h.Proxy
+	case "Password":
+		return h.Password
+	case "ProxyJump":
+		for _, attrib := range h.OtherAttribs {
+			if jump, ok := strings.CutPrefix(attrib, "ProxyJump "); ok {
+				return jump
+			}
+		}
+	}
+	return ""
+}
+
+// sshOptions turns the inherited values into options of the ssh and sftp
+// commands, which only read the profile from the ssh config.
+func sshOptions(h *SSHConfig, inherited map[string]string) []string {
+	var options []string
+	for _, key := range folderDefaultKeys {
+		if _, ok := inherited[key]; ok && key != "Password" {
+			options = append(options, "-o", key+"="+defaultValue(h, key))
+		}
+	}
+	return options
+}
+
+// folderDefaultsMenu shows the defaults of the folder and what its hosts
+// inherit from the folders above, and runs the chosen change.
+func (s *AllConfigs) folderDefaultsMenu(folder string) error {
+	own, err := readFolderDefaults(folder)
+	if err != nil {
+		return err
+	}
+	values, from := inheritedDefaults(folder)
+
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("Defaults of %s%s%s, the hosts of the folder and its subfolders get what their profile leaves out:\n\n", magenta, folder, reset))
+	for _, key := range folderDefaultKeys {
+		value, ok := values[key]
+		switch {
+		case !ok:
+			value = "-"
+		case key == "Password" || isSecure:
+			value = private
+		}
+		if ok && from[key] != folder {
+			value += fmt.Sprintf("  %s(from %s)%s", blue, from[key], reset)
+		}
+		message.WriteString(fmt.Sprintf("    %-13s %s\n", key+":", value))
+	}
+	message.WriteString("\n")
+
+	var items []string
+	for _, key := range folderDefaultKeys {
+		items = append(items, "Set "+key)
+	}
+	items = append(items, "Clear a default", "Write the defaults into the ssh config", goback)
+
+	command, err := main_ui(items, message.String(), false)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case goback:
+		return errGoBack
+
+	case "Clear a default":
+		var set []string
+		for _, key := range folderDefaultKeys {
+			if _, ok := own[key]; ok {
+				set = append(set, key)
+			}
+		}
+		if len(set) == 0 {
+			return fmt.Errorf("%s has no defaults of its own", folder)
+		}
+
+		key, err := main_ui(append(set, goback), "Select the default to clear:\n\n", false)
+		if err != nil || key == goback {
+			return errGoBack
+		}
+		doConfigBackup("db")
+		return writeFolderDefault(folder, key, "")
+
+	case "Write the defaults into the ssh config":
+		return s.materializeFolderDefaults(folder)
+	}
+
+	key := strings.TrimPrefix(command, "Set ")
+	var value string
+	if key == "Password" {
+		fmt.Print("\nEnter the default password: ")
+		bytePassword, err := term.ReadPassword(uintptr(syscall.Stdin))
+		fmt.Println()
+		if err != nil {
+			return fmt.Errorf("error reading password: %w", err)
+		}
+		value = string(bytePassword)
+	} else {
+		fmt.Printf("%s for the hosts of %s: ", key, folder)
+		scanner := bufio.NewScanner(os.Stdin)
+		if scanner.Scan() {
+			value = strings.TrimSpace(scanner.Text())
+		}
+	}
+
+	if value == "" {
+		return fmt.Errorf("the %s default is empty, use 'Clear a default' to remove it", key)
+	}
+	if _, err := strconv.Atoi(value); key == "Port" && err != nil {
+		return fmt.Errorf("invalid port %q", value)
+	}
+
+	doConfigBackup("db")
+	return writeFolderDefault(folder, key, value)
+}
+
+// materializeFolderDefaults writes what the hosts of the folder and its
+// subfolders inherit into their profiles, the passwords into the database.
+// The defaults stay, for the hosts added to the folder later.
+func (s *AllConfigs) materializeFolderDefaults(folder string) error {
+	doConfigBackup("all")
+
+	passwords := map[string][]string{}
+	changed := 0
+	for i := range *s {
+		h := &(*s)[i]
+		if h.Folder != folder && !strings.HasPrefix(h.Folder, folder+"/") {
+			continue
+		}
+
+		// The stored password decides, getItems may not have read it
+		probe := *h
+		probe.Password, _ = s.readAndDecryptFromDB(h.Host, "password", true)
+
+		effective, inherited := s.withFolderDefaults(&probe)
+		if len(inherited) == 0 {
+			continue
+		}
+		changed++
+
+		h.User, h.Port, h.IdentityFile, h.Proxy, h.OtherAttribs = effective.User, effective.Port, effective.IdentityFile, effective.Proxy, effective.OtherAttribs
+		if _, ok := inherited["Password"]; ok {
+			passwords[effective.Password] = append(passwords[effective.Password], h.Host)
+		}
+	}
+
+	if err := s.pushConfigToFile(); err != nil {
+		return fmt.Errorf("error writing the folder defaults to the ssh config: %w", err)
+	}
+
+	for password, hosts := range passwords {
+		if err := encryptAndPushToDBForHosts(hosts, "password", password); err != nil {
+			return fmt.Errorf("failed to store the default password: %w", err)
+		}
+	}
+
+	fmt.Printf("The defaults of %s were written into %d profiles.\n", folder, changed)
+	pressEnter()
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Folders are paths: a / in the name of a folder makes it a subfolder, like
+// prod/eu/db. The folders in between exist as long as a host is below them.
+
+// parentFolder returns the folder above, "" for a top level folder.
+func parentFolder(folder string) string {
+	if i := strings.LastIndex(folder, "/"); i >= 0 {
+		return folder[:i]
+	}
+	return ""
+}
+
+// folderBase returns the last part of the folder path, the name shown in the list.
+func folderBase(folder string) string {
+	return folder[strings.LastIndex(folder, "/")+1:]
+}
+
+// subFolderPath returns the path of the subfolder name of folder.
+func subFolderPath(folder, name string) string {
+	if folder == "" {
+		return name
+	}
+	return folder + "/" + name
+}
+
+// cleanFolderPath checks a folder path typed by the user and drops the extra
+// slashes and spaces around its parts.
+func cleanFolderPath(name string) (string, error) {
+	var parts []string
+	for _, part := range strings.Split(strings.Trim(strings.TrimSpace(name), "/"), "/") {
+		part = strings.TrimSpace(part)
+		if part == "" || part == "NULL" {
+			return "", fmt.Errorf("invalid folder name %q", name)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// folderTree returns every folder path, the ones in between included, sorted
+// so the subfolders come right after their parent.
+func folderTree(folders []string) []string {
+	var tree []string
+	for _, f := range folders {
+		if f == "" || f == "NULL" {
+			continue
+		}
+		for p := f; p != ""; p = parentFolder(p) {
+			tree = append(tree, p)
+		}
+	}
+
+	slices.SortFunc(tree, func(a, b string) int {
+		return slices.Compare(strings.Split(a, "/"), strings.Split(b, "/"))
+	})
+	return slices.Compact(tree)
+}
+
+// subFolders returns the names of the folders right below folder.
+func subFolders(folders []string, folder string) []string {
+	var names []string
+	for _, f := range folderTree(folders) {
+		if parentFolder(f) == folder {
+			names = append(names, folderBase(f))
+		}
+	}
+	return names
+}
+
+// pickFolderFromTree shows the folders indented under their parent, followed
+// by the extra items, and returns the path of the chosen folder or the extra
+// item.
+func pickFolderFromTree(folders []string, message string, extra ...string) (string, error) {
+	paths := map[string]string{}
+	var items []string
+	for _, f := range folderTree(folders) {
+		item := fmt.Sprintf("%s%s %s%s%s", strings.Repeat("   ", strings.Count(f, "/")), folderIcon, magenta, folderBase(f), reset)
+		paths[item] = f
+		items = append(items, item)
+	}
+	items = append(items, extra...)
+
+	chosen, err := main_ui(items, message, false)
+	if err != nil {
+		return "", err
+	}
+
+	if f, ok := paths[chosen]; ok {
+		return f, nil
+	}
+	return chosen, nil
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -24,6 +25,7 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/x/term"
 	_ "modernc.org/sqlite"
@@ -66,6 +68,60 @@ func initDB(filepath string) error {
 			data_bits INTEGER,
 			folder TEXT
 		);`,
+
+		"sftp_bookmarks": `
+		CREATE TABLE IF NOT EXISTS sftp_bookmarks (
+			host TEXT NOT NULL,
+			path TEXT NOT NULL,
+			PRIMARY KEY (host, path)
+		);`,
+
+		"transfers": `
+		CREATE TABLE IF NOT EXISTS transfers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time TEXT NOT NULL,
+			host TEXT NOT NULL,
+			direction TEXT NOT NULL,
+			source TEXT NOT NULL,
+			target TEXT NOT NULL,
+			bytes INTEGER NOT NULL,
+			duration_ms INTEGER NOT NULL,
+			checksum TEXT NOT NULL,
+			result TEXT NOT NULL
+		);`,
+
+		"settings": `
+		CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT
+		);`,
+
+		"folder_defaults": `
+		CREATE TABLE IF NOT EXISTS folder_defaults (
+			folder TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT,
+			PRIMARY KEY (folder, key)
+		);`,
+
+		"tags": `
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE
+		);`,
+
+		"host_tags": `
+		CREATE TABLE IF NOT EXISTS host_tags (
+			host TEXT NOT NULL,
+			tag_id INTEGER NOT NULL REFERENCES tags(id),
+			PRIMARY KEY (host, tag_id)
+		);`,
+
+		"smart_folders": `
+		CREATE TABLE IF NOT EXISTS smart_folders (
+			name TEXT PRIMARY KEY,
+			query TEXT NOT NULL
+		);`,
 	}
 
 	// Execute each CREATE TABLE statement.
@@ -75,15 +131,14 @@ func initDB(filepath string) error {
 		}
 	}
 
-	// Add url and sshkey_passphrase columns to an existing database
+	// Add the columns that were introduced after the first release to an existing database
 	rows, err := db.Query("PRAGMA table_info(sshprofiles)")
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer rows.Close()
 
-	urlColumnExists := false
-	passphraseColumnExists := false
+	existingColumns := map[string]bool{}
 	for rows.Next() {
 		var (
 			cid      int
@@ -96,29 +151,26 @@ func initDB(filepath string) error {
 		if err := rows.Scan(&cid, &name, &dataType, &notNull, &dfltVal, &pk); err != nil {
 			log.Fatal(err)
 		}
-		if name == "url" {
-			urlColumnExists = true
-		}
-		if name == "sshkey_passphrase" {
-			passphraseColumnExists = true
-		}
-
+		existingColumns[name] = true
 	}
 
-	if !urlColumnExists {
-		_, err := db.Exec("ALTER TABLE sshprofiles ADD COLUMN url TEXT")
-		if err != nil {
-			log.Fatalf("failed to add url column: %v", err)
-		}
-		fmt.Println("Successfully added the 'url' column.")
+	newColumns := []struct{ name, definition string }{
+		{"url", "TEXT"},
+		{"sshkey_passphrase", "TEXT"},
+		{"sftp_path", "TEXT"},
+		{"last_connected", "TEXT"},
+		{"connect_count", "INTEGER"},
+		{"favorite", "INTEGER"},
 	}
 
-	if !passphraseColumnExists {
-		_, err := db.Exec("ALTER TABLE sshprofiles ADD COLUMN sshkey_passphrase TEXT")
-		if err != nil {
-			log.Fatalf("failed to add sshkey_passphrase column: %v", err)
+	for _, c := range newColumns {
+		if existingColumns[c.name] {
+			continue
 		}
-		fmt.Println("Successfully added the 'sshkey_passphrase' column.")
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE sshprofiles ADD COLUMN %s %s", c.name, c.definition)); err != nil {
+			log.Fatalf("failed to add %s column: %v", c.name, err)
+		}
+		log.Printf("Successfully added the '%s' column.", c.name)
 	}
 
 	return nil
@@ -473,6 +525,31 @@ func openBrowser(url string) error {
 	return err
 }
 
+// runAttached runs cmd on the terminal. Ctrl+C only stops the command, so
+// sshcli can go back to the menu when it ends.
+func runAttached(cmd *exec.Cmd) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	return cmd.Run()
+}
+
+// pressEnter keeps the output of a command on the screen until it's read,
+// before the menu takes the terminal again.
+func pressEnter() {
+	if readBoolSetting("exit_after_connect") {
+		return
+	}
+
+	fmt.Printf("\n%sPress Enter to go back to the menu.%s", yellow, reset)
+	bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
 func checkShellCommands(c string) error {
 	if _, err := exec.LookPath(c); err != nil {
 		return fmt.Errorf("%v command is not available in the default system shell", c)
@@ -653,6 +730,14 @@ func cleanupDatabase() error {
 	rowsDeleted, _ := result.RowsAffected()
 	fmt.Printf("Successfully deleted %d rows.\n", rowsDeleted)
 
+	// The tags of the hosts that are gone go with them
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM host_tags WHERE host NOT IN (%s)", strings.Join(placeholders, ",")), args...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(dropUnusedTags); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -668,6 +753,11 @@ func processCliArgs() (ConsoleConfig, *string) {
 	sanitizeDB := flag.Bool("cleanup", false, "delete sqlite records that are not in the ssh config file")
 	secure := flag.Bool("secure", false, "Masks the sensitive data")
 	sql := flag.Bool("sql", false, "Direct access to the sshcli.db file to run sql queries")
+	transfers := flag.Bool("transfers", false, "lists the transfer history, filtered by -host, -since and -until")
+	since := flag.String("since", "", "with -transfers: only the transfers from this date on (YYYY-MM-DD)")
+	until := flag.String("until", "", "with -transfers: only the transfers until this date (YYYY-MM-DD)")
+	set := flag.String("set", "", "changes a setting: -set key=value, see -settings")
+	settings := flag.Bool("settings", false, "lists the settings and their values")
 
 	flag.Parse()
 
@@ -695,6 +785,32 @@ func processCliArgs() (ConsoleConfig, *string) {
 		os.Exit(0)
 	}
 
+	if *transfers {
+		code := 0
+		if err := printTransfers(*host, *since, *until); err != nil {
+			fmt.Println(err)
+			code = 1
+		}
+		db.Close()
+		os.Exit(code)
+	}
+
+	if *set != "" {
+		code := 0
+		if err := writeSetting(*set); err != nil {
+			fmt.Println(err)
+			code = 1
+		}
+		db.Close()
+		os.Exit(code)
+	}
+
+	if *settings {
+		printSettings()
+		db.Close()
+		os.Exit(0)
+	}
+
 	if *secure {
 		isSecure = true
 	}
@@ -845,40 +961,47 @@ func (s *AllConfigs) AddSocksToProfile(hostName string) error {
 func (s *AllConfigs) constructConfigContent() []string {
 	configLines := []string{}
 	for _, c := range *s {
-		configLines = append(configLines, "Host "+c.Host)
+		configLines = append(configLines, c.configBlock()...)
+		configLines = append(configLines, "")
+	}
 
-		if len(c.HostName) != 0 {
-			configLines = append(configLines, "    HostName "+c.HostName)
-		}
-		if len(c.User) != 0 {
-			configLines = append(configLines, "    User "+c.User)
-		}
-		if len(c.Port) != 0 {
-			configLines = append(configLines, "    Port "+c.Port)
-		}
-		if len(c.IdentityFile) != 0 {
-			configLines = append(configLines, "    IdentityFile "+c.IdentityFile)
-		}
-		if len(c.Proxy) != 0 {
-			configLines = append(configLines, "    ProxyCommand "+c.Proxy)
-		}
-		if len(c.Sockets) != 0 {
-			for _, socket := range c.Sockets {
-				configLines = append(configLines, "    "+socket)
-			}
+	return configLines
+}
+
+// configBlock returns the lines of the host in the ssh config file.
+func (c SSHConfig) configBlock() []string {
+	configLines := []string{"Host " + c.Host}
+
+	if len(c.HostName) != 0 {
+		configLines = append(configLines, "    HostName "+c.HostName)
+	}
+	if len(c.User) != 0 {
+		configLines = append(configLines, "    User "+c.User)
+	}
+	if len(c.Port) != 0 {
+		configLines = append(configLines, "    Port "+c.Port)
+	}
+	if len(c.IdentityFile) != 0 {
+		configLines = append(configLines, "    IdentityFile "+c.IdentityFile)
+	}
+	if len(c.Proxy) != 0 {
+		configLines = append(configLines, "    ProxyCommand "+c.Proxy)
+	}
+	if len(c.Sockets) != 0 {
+		for _, socket := range c.Sockets {
+			configLines = append(configLines, "    "+socket)
 		}
+	}
 
-		if len(c.DynamicSocks) != 0 {
-			for _, s := range c.DynamicSocks {
-				configLines = append(configLines, "    "+s)
-			}
+	if len(c.DynamicSocks) != 0 {
+		for _, s := range c.DynamicSocks {
+			configLines = append(configLines, "    "+s)
 		}
-		if len(c.OtherAttribs) != 0 {
-			for _, o := range c.OtherAttribs {
-				configLines = append(configLines, "    "+o)
-			}
+	}
+	if len(c.OtherAttribs) != 0 {
+		for _, o := range c.OtherAttribs {
+			configLines = append(configLines, "    "+o)
 		}
-		configLines = append(configLines, "")
 	}
 
 	return configLines
@@ -1066,6 +1189,10 @@ func (s *AllConfigs) readNoteforHost(host string, needString bool) (string, erro
 
 func (s *AllConfigs) getItems(folder string) []string {
 
+	if isSmartFolder(folder) {
+		return s.smartFolderItems(folder)
+	}
+
 	items := make([]string, 0)
 
 	maxHostLen := 1
@@ -1098,27 +1225,12 @@ func (s *AllConfigs) getItems(folder string) []string {
 		folderlist = append(folderlist, f)
 	}
 
-	slices.Sort(folderlist)
-
-	folderlist = slices.Compact(folderlist)
-
+	// The folders right below this one, by their name
+	for _, f := range subFolders(folderlist, folder) {
+		items = append(items, fmt.Sprintf("%s %s %s%s", folderIcon, magenta, f, reset))
+	}
 	if folder == "" {
-		// for _, f := range folderhost {
-		// 	for _, host := range *s {
-		// 		if host.Folder == f {
-		// 			items = append(items, fmt.Sprintf("%s %s %s%s", folderIcon, magenta, f, reset))
-		// 			break
-		// 		}
-		// 	}
-		// }
-
-		for _, f := range folderlist {
-			if f != "NULL" && f != "" {
-				items = append(items, fmt.Sprintf("%s %s %s%s", folderIcon, magenta, f, reset))
-			}
-		}
-
-		slices.Sort(items)
+		items = append(items, smartFolderLines()...)
 	}
 
 	connectionItems := map[string]SSHConfig{}
@@ -1203,11 +1315,13 @@ func (s *AllConfigs) getItems(folder string) []string {
 			item += "(  )"
 		}
 
-		if url, err := s.readUrlFromDb(host.Host); err == nil && len(url) > 0 {
+		url, err := s.readUrlFromDb(host.Host)
+		if err == nil && len(url) > 0 {
 			item += "(" + hasUrl + ")"
 		} else {
 			item += "(  )"
 		}
+		indexHost(host, url)
 
 		if val, _ := s.readNoteforHost(host.Host, false); val == "ok" {
 			item += "(" + hasNote + " )"
@@ -1253,31 +1367,68 @@ func (s *AllConfigs) getItems(folder string) []string {
 	items = append(items, consoleItems...)
 
 	if folder == "" {
+		// The favorites and recent hosts of every folder come first
+		items = append(s.pinnedSections(connectionItemsNewFormat, folderhost), items...)
 		items = append([]string{sshIcon + " New SSH Profile"}, items...)
 	}
 
 	if folder != "" {
-		items = append(items, goback)
+		items = append(items, folderDefaultsItem, goback)
 	}
 
 	return items
 }
 
-func (s *AllConfigs) navigateToNext(chosen string) error {
+// navigateToNext runs what was chosen in the list of folder and returns the
+// folder to show next. acted tells if an action ran, rather than a folder
+// being opened or left.
+func (s *AllConfigs) navigateToNext(chosen, folder string) (string, bool, error) {
 
 	if chosen == goback {
-		if err := s.InitUi(""); err != nil {
-			if !strings.Contains(err.Error(), "^C") {
-				return fmt.Errorf("failed to back to the parent ui")
-			} else {
-				log.Println(err)
-				return fmt.Errorf(`
-      (o o)
---oOO--(_)--OOo--
- Have a nice day!
-                    			`)
+		return parentFolder(folder), false, nil
+	}
+
+	if query, ok := strings.CutPrefix(chosen, saveSearchPrefix); ok {
+		name, err := saveSmartFolder(query)
+		if err != nil {
+			if errors.Is(err, errGoBack) {
+				return folder, false, nil
+			}
+			return folder, true, err
+		}
+		return smartFolderIcon + " " + name, false, nil
+	}
+
+	if line, ok := strings.CutPrefix(chosen, toggleFavoritePrefix); ok {
+		return folder, false, toggleFavorite(hostAlias(line))
+	}
+
+	if chosen == favoritesHeader || chosen == recentHeader {
+		return folder, false, nil
+	}
+
+	if chosen == removeSmartFolderItem {
+		if err := removeSmartFolder(smartFolderName(folder)); err != nil {
+			if errors.Is(err, errGoBack) {
+				return folder, false, nil
 			}
+			return folder, true, err
 		}
+		return "", true, nil
+	}
+
+	if isSmartFolder(stripAnsi(chosen)) {
+		return stripAnsi(chosen), false, nil
+	}
+
+	if chosen == folderDefaultsItem {
+		if err := s.folderDefaultsMenu(folder); err != nil {
+			if errors.Is(err, errGoBack) {
+				return folder, false, nil
+			}
+			return folder, true, fmt.Errorf("'%s': %w", folder, err)
+		}
+		return folder, true, nil
 	}
 
 	chosen_type := ""
@@ -1289,43 +1440,51 @@ func (s *AllConfigs) navigateToNext(chosen string) error {
 	} else if strings.Contains(chosen, sshIcon) {
 		chosen_type = "ssh"
 	} else {
-		return fmt.Errorf("unknown entry")
+		return folder, false, fmt.Errorf("unknown entry")
 	}
 
 	if chosen_type == "folder" {
-		CleanedChosen := cleanTheString(chosen, "all")
+		return subFolderPath(folder, cleanTheString(chosen, "all")), false, nil
+	}
 
-		if err := s.InitUi(CleanedChosen); err != nil {
-			if !strings.Contains(err.Error(), "^C") {
-				log.Println(err)
-				return fmt.Errorf(`
-      (o o)
---oOO--(_)--OOo--
- Have a nice day!
-                    			`)
-			}
-			return err
+	if chosen == sshIcon+" New SSH Profile" {
+		doConfigBackup("all")
+		if err := s.editProfile("", "new"); err != nil {
+			return folder, true, fmt.Errorf("failed to create a new profile: %w", err)
 		}
-	} else {
-		if chosen == sshIcon+" New SSH Profile" {
-			doConfigBackup("all")
-			if err := s.editProfile("", "new"); err != nil {
-				return fmt.Errorf("failed to create a new profile: %w", err)
-			}
+		return folder, true, nil
+	}
 
-		} else {
-			if err := s.Connect(chosen); err != nil {
-				chosenParts := strings.Split(chosen, " ")
-				return fmt.Errorf("'%s': %w", chosenParts[1], err)
-			}
+	if err := s.Connect(chosen); err != nil {
+		if errors.Is(err, errGoBack) {
+			return folder, false, nil
 		}
+		chosenParts := strings.Split(chosen, " ")
+		return folder, true, fmt.Errorf("'%s': %w", chosenParts[1], err)
 	}
 
-	return nil
+	return folder, true, nil
 }
 
 func (s *AllConfigs) moveToFolder(host string) error {
 
+	new_folder_name, currentFolder, err := s.pickFolder(host, true)
+	if err != nil {
+		return err
+	}
+
+	if err := s.updateProfileFolder(host, new_folder_name, currentFolder); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// pickFolder asks for the folder to move to. It returns the new folder name,
+// "NULL" to take the host out of its folder, and the folder to rename when
+// canRename is set and the user chose to rename one instead.
+func (s *AllConfigs) pickFolder(host string, canRename bool) (string, string, error) {
+
 	folderhost, err := s.getFolderList()
 	if err != nil {
 		log.Println("failed to retrieve the folder list:%w", err)
@@ -1337,26 +1496,40 @@ func (s *AllConfigs) moveToFolder(host string) error {
 		FolderList = append(FolderList, v)
 	}
 
-	slices.Sort(FolderList)
-
-	FolderList = slices.Compact(FolderList)
-
-	FolderList = append(FolderList, "New Folder")
-	FolderList = append(FolderList, "Rename Folder")
-	FolderList = append(FolderList, "Remove from folder")
-	folderName := ""
+	extra := []string{"New Folder"}
+	if canRename {
+		extra = append(extra, "Rename Folder")
+	}
+	extra = append(extra, "Remove from folder")
 
-	folderName, err = main_ui(FolderList, "Select the folder to move to:\n\n", false)
+	folderName, err := pickFolderFromTree(FolderList, "Select the folder to move to:\n\n", extra...)
 	if err != nil {
 		handleExitSignal(err)
-		return fmt.Errorf("error selecting folder: %w", err)
+		return "", "", fmt.Errorf("error selecting folder: %w", err)
 	}
 
 	new_folder_name := ""
 	currentFolder := ""
 	if strings.EqualFold(folderName, "New Folder") || strings.EqualFold(folderName, "Rename folder") {
+
+		prompt := "New folder name, use / for subfolders (eg. prod/eu/db): "
+		if strings.EqualFold(folderName, "Rename folder") {
+			current, _ := readFolderForHostFromDB(host)
+			message := "Select the folder to rename, its subfolders follow it:\n\n"
+			if current != "" {
+				message = fmt.Sprintf("Select the folder to rename (%s is in %s), its subfolders follow it:\n\n", host, current)
+			}
+
+			currentFolder, err = pickFolderFromTree(FolderList, message)
+			if err != nil {
+				handleExitSignal(err)
+				return "", "", fmt.Errorf("error selecting folder: %w", err)
+			}
+			prompt = fmt.Sprintf("New name for %s, a path with / moves it (eg. %s): ", currentFolder, subFolderPath("archive", folderBase(currentFolder)))
+		}
+
 		var name string
-		fmt.Print("New folder name: ")
+		fmt.Print(prompt)
 		scanner := bufio.NewScanner(os.Stdin)
 		if scanner.Scan() {
 			name = scanner.Text()
@@ -1366,33 +1539,36 @@ func (s *AllConfigs) moveToFolder(host string) error {
 		}
 
 		if len(name) == 0 {
-			return fmt.Errorf("invalid folder name")
+			return "", "", fmt.Errorf("invalid folder name")
 		}
 
-		if slices.Contains(FolderList, name) {
-			return fmt.Errorf("folder with name %s already exists", name)
+		// A plain name renames the folder where it is
+		if currentFolder != "" && !strings.Contains(name, "/") {
+			name = subFolderPath(parentFolder(currentFolder), name)
 		}
 
-		new_folder_name = name
+		name, err = cleanFolderPath(name)
+		if err != nil {
+			return "", "", err
+		}
 
-		if strings.EqualFold(folderName, "Rename folder") {
-			currentFolder, err = readFolderForHostFromDB(host)
-			if err != nil {
-				return fmt.Errorf("error reading folder for host from db: %w", err)
-			}
+		if slices.Contains(folderTree(FolderList), name) {
+			return "", "", fmt.Errorf("folder with name %s already exists", name)
+		}
+
+		if currentFolder != "" && strings.HasPrefix(name, currentFolder+"/") {
+			return "", "", fmt.Errorf("can't move %s into itself", currentFolder)
 		}
 
+		new_folder_name = name
+
 	} else if strings.EqualFold(folderName, "Remove from folder") {
 		new_folder_name = "NULL"
 	} else {
 		new_folder_name = folderName
 	}
 
-	if err := s.updateProfileFolder(host, new_folder_name, currentFolder); err != nil {
-		return err
-	}
-
-	return nil
+	return new_folder_name, currentFolder, nil
 }
 
 func (s *AllConfigs) updateProfileFolder(hostname, newFolderName, currentFolderName string) error {
@@ -1404,8 +1580,9 @@ func (s *AllConfigs) updateProfileFolder(hostname, newFolderName, currentFolderN
 
 	sql_clause := "INSERT INTO sshprofiles(host,folder) VALUES(?,?) ON CONFLICT(host) DO UPDATE SET folder = excluded.folder;"
 
+	// Renaming a folder renames the path of its subfolders too
 	if len(currentFolderName) > 0 {
-		sql_clause = "UPDATE sshprofiles SET folder = ? WHERE folder = ?;"
+		sql_clause = "UPDATE sshprofiles SET folder = ? || substr(folder, ?) WHERE folder = ? OR substr(folder, 1, ?) = ?;"
 	}
 
 	stmt, err := tx.Prepare(sql_clause)
@@ -1415,7 +1592,8 @@ func (s *AllConfigs) updateProfileFolder(hostname, newFolderName, currentFolderN
 	defer stmt.Close()
 
 	if len(currentFolderName) > 0 {
-		_, err = stmt.Exec(newFolderName, currentFolderName)
+		length := utf8.RuneCountInString(currentFolderName) + 1
+		_, err = stmt.Exec(newFolderName, length, currentFolderName, length, currentFolderName+"/")
 	} else if newFolderName == "NULL" {
 		_, err = stmt.Exec(hostname, sql.NullString{String: "", Valid: false})
 	} else {
@@ -1424,6 +1602,17 @@ func (s *AllConfigs) updateProfileFolder(hostname, newFolderName, currentFolderN
 	if err != nil {
 		return fmt.Errorf("failed to update the folder for the host %v: %v", hostname, err)
 	}
+
+	// The defaults of the renamed folders go with them
+	if len(currentFolderName) > 0 {
+		length := utf8.RuneCountInString(currentFolderName) + 1
+		_, err = tx.Exec("UPDATE folder_defaults SET folder = ? || substr(folder, ?) WHERE folder = ? OR substr(folder, 1, ?) = ?;",
+			newFolderName, length, currentFolderName, length, currentFolderName+"/")
+		if err != nil {
+			return fmt.Errorf("failed to rename the defaults of the folder %v: %v", currentFolderName, err)
+		}
+	}
+
 	return tx.Commit()
 }
 
@@ -1532,6 +1721,45 @@ func WriteNoteToDb(note, host string) error {
 	return tx.Commit()
 }
 
+// writeConnection records that a session to the host was started now.
+func writeConnection(host string) error {
+
+	updateQuery := "INSERT INTO sshprofiles (host, last_connected, connect_count) VALUES (?, ?, 1) ON CONFLICT(host) DO UPDATE SET last_connected = excluded.last_connected, connect_count = coalesce(connect_count, 0) + 1;"
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the db transaction for the connection time:%v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(updateQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare update statement: %w", err)
+	}
+	defer stmt.Close()
+
+	if _, err = stmt.Exec(host, time.Now().Format(historyTimeFormat)); err != nil {
+		return fmt.Errorf("failed to update the connection time for host %s: %w", host, err)
+	}
+
+	return tx.Commit()
+}
+
+// readConnections returns when the host was connected to last and how many
+// times it was.
+func readConnections(host string) (string, int, error) {
+	var (
+		lastConnected sql.NullString
+		count         sql.NullInt64
+	)
+	err := db.QueryRow("SELECT last_connected, connect_count FROM sshprofiles WHERE host = ?", host).Scan(&lastConnected, &count)
+	if err != nil && err != sql.ErrNoRows {
+		return "", 0, fmt.Errorf("error reading the connections of %v from db: %w", host, err)
+	}
+
+	return lastConnected.String, int(count.Int64), nil
+}
+
 func (s *AllConfigs) writeUrlDb(hostname string) error {
 	var url string
 
@@ -1698,6 +1926,24 @@ func IsProxyValid(s string) (string, error) {
 }
 
 func (s *AllConfigs) AddProxyToProfile(hostName string) error {
+	proxy, err := readProxy()
+	if err != nil {
+		return err
+	}
+
+	if h := s.extractHost(hostName); h != nil {
+		h.Proxy = proxy
+		if err := s.pushConfigToFile(); err != nil {
+			return fmt.Errorf("error adding/updating profile: %w", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("error extracting host: %s", hostName)
+	}
+}
+
+// readProxy asks for an http proxy and returns the ProxyCommand using it.
+func readProxy() (string, error) {
 	var proxy string
 	fmt.Print("Enter httpproxy IP:Port (eg. 10.10.10.10:8080) or press enter to read it from https_proxy env variable: ")
 	scanner := bufio.NewScanner(os.Stdin)
@@ -1713,30 +1959,19 @@ func (s *AllConfigs) AddProxyToProfile(hostName string) error {
 		//read https_proxy value and use use it
 		proxy = os.Getenv("https_proxy")
 		if proxy == "" {
-			return fmt.Errorf("https_proxy is not set")
+			return "", fmt.Errorf("https_proxy is not set")
 		}
 	}
 
 	cleanProxy, err := IsProxyValid(proxy)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if runtime.GOOS == "darwin" {
-		proxy = "nc -X connect -x " + cleanProxy + " %h %p"
-	} else {
-		proxy = "ncat --proxy " + cleanProxy + " --proxy-type http %h %p"
-	}
-
-	if h := s.extractHost(hostName); h != nil {
-		h.Proxy = proxy
-		if err := s.pushConfigToFile(); err != nil {
-			return fmt.Errorf("error adding/updating profile: %w", err)
-		}
-		return nil
-	} else {
-		return fmt.Errorf("error extracting host: %s", hostName)
+		return "nc -X connect -x " + cleanProxy + " %h %p", nil
 	}
+	return "ncat --proxy " + cleanProxy + " --proxy-type http %h %p", nil
 }
 
 func (s *AllConfigs) DeleteProxyFromProfile(hostName string) error {
@@ -1803,6 +2038,10 @@ func (s *AllConfigs) updateHostNameInDatabase(oldhostname, newhostname string) e
 		return fmt.Errorf("failed to update hostname for host %s: %w", oldhostname, err)
 	}
 
+	if _, err := tx.Exec("UPDATE OR REPLACE host_tags SET host = ? WHERE host = ?", newhostname, oldhostname); err != nil {
+		return fmt.Errorf("failed to move the tags of host %s: %w", oldhostname, err)
+	}
+
 	return tx.Commit()
 }
 
@@ -2083,17 +2322,7 @@ func (s *AllConfigs) Connect(chosen string) error {
 		}
 
 		if command == goback {
-			if err := s.InitUi(h.Folder); err != nil {
-				if !strings.Contains(err.Error(), "^C") {
-					return fmt.Errorf("failed to back to the parent ui")
-				} else {
-					return fmt.Errorf(`
-      (o o)
---oOO--(_)--OOo--
- Have a nice day!
-                    			`)
-				}
-			}
+			return errGoBack
 		}
 
 		command = cleanTheString(command, "keyboard")
@@ -2103,6 +2332,14 @@ func (s *AllConfigs) Connect(chosen string) error {
 			if err := s.moveToFolder(hostName); err != nil {
 				return fmt.Errorf("Failed to set folder for %s: %w", hostName, err)
 			}
+		} else if strings.EqualFold(command, "Set Tags") {
+			doConfigBackup("db")
+			if err := setTags(hostName); err != nil {
+				if errors.Is(err, errGoBack) {
+					return err
+				}
+				return fmt.Errorf("failed to set the tags of %s: %w", hostName, err)
+			}
 		} else if strings.EqualFold(command, "Notes") {
 			if err := s.updateNotesAndPushToDb(hostName); err != nil {
 				log.Println(err)
@@ -2149,6 +2386,7 @@ func (s *AllConfigs) Connect(chosen string) error {
 					fmt.Println("Password for", hostName, ":", h.Password)
 				}
 			}
+			pressEnter()
 
 		} else if strings.EqualFold(command, "Reveal sshkey passphrase") {
 			if len(h.sshkey_passphrase) == 0 {
@@ -2164,6 +2402,7 @@ func (s *AllConfigs) Connect(chosen string) error {
 					fmt.Println("sshkey passphrase for", hostName, ":", h.sshkey_passphrase)
 				}
 			}
+			pressEnter()
 		} else if strings.EqualFold(command, "Remove sshkey passphrase") {
 			doConfigBackup("all")
 			if err := SetNullValue(hostName, "sshkey_passphrase"); err != nil {
@@ -2227,12 +2466,11 @@ func (s *AllConfigs) Connect(chosen string) error {
 			}
 		} else if strings.EqualFold(command, "ping") {
 			cmd := *exec.Command(strings.ToLower(command), h.HostName)
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			cmd.Run()
+			runAttached(&cmd)
+			pressEnter()
 
 		} else if strings.EqualFold(command, "tcping") {
+			h, _ := s.withFolderDefaults(h)
 			if err := checkShellCommands(strings.ToLower(command)); err != nil {
 				log.Println("tcping is not installed. install by checking https://github.com/pouriyajamshidi/tcping")
 				fmt.Println("tcping is not installed. install by checking https://github.com/pouriyajamshidi/tcping")
@@ -2241,12 +2479,11 @@ func (s *AllConfigs) Connect(chosen string) error {
 					port = h.Port
 				}
 				cmd := *exec.Command(strings.ToLower(command), h.HostName, port)
-				cmd.Stdin = os.Stdin
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				cmd.Run()
+				runAttached(&cmd)
 			}
+			pressEnter()
 		} else if strings.EqualFold(command, "ssh-copy-id") {
+			h, _ := s.withFolderDefaults(h)
 			if err := checkShellCommands("ssh-copy-id"); err != nil {
 				fmt.Println(err.Error())
 				return fmt.Errorf("ssh-copy-id command not found: %w", err)
@@ -2282,12 +2519,11 @@ func (s *AllConfigs) Connect(chosen string) error {
 				}
 			}
 
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			cmd.Run()
+			runAttached(&cmd)
+			pressEnter()
 
-		} else if strings.EqualFold(command, "sftp (text UI)") {
+		} else if strings.EqualFold(command, "sftp (text UI)") || strings.EqualFold(command, "sftp (text UI, sudo)") {
+			h, _ := s.withFolderDefaults(h)
 			if h.Port == "" {
 				h.Port = "22"
 			}
@@ -2296,7 +2532,12 @@ func (s *AllConfigs) Connect(chosen string) error {
 				h.IdentityFile = strings.ReplaceAll(h.IdentityFile, "~", homeDir)
 			}
 
-			err = INIT_SFTP(h.Host, h.HostName, h.User, h.Password, h.Port, h.IdentityFile, h.sshkey_passphrase)
+			if err := writeConnection(hostName); err != nil {
+				log.Println(err)
+			}
+
+			sudo := strings.EqualFold(command, "sftp (text UI, sudo)")
+			err = INIT_SFTP(h.Host, h.HostName, h.User, h.Password, h.Port, h.IdentityFile, h.sshkey_passphrase, sudo)
 			if err != nil {
 				if strings.Contains(err.Error(), "methods [none], no supported methods remain") {
 					errMsg := "\n - Can't authenticate to the server. no password or key provided. \n\n"
@@ -2331,7 +2572,7 @@ func (s *AllConfigs) Connect(chosen string) error {
 				if userAction == "Yes" {
 					command = "ssh"
 				} else {
-					os.Exit(0)
+					return nil
 				}
 
 			}
@@ -2343,7 +2584,11 @@ func (s *AllConfigs) Connect(chosen string) error {
 				return fmt.Errorf("command not found: %w", err)
 			}
 
-			cmd := *exec.Command(strings.ToLower(command), hostName)
+			// ssh reads the profile itself, what it inherits from the folder is passed along
+			h, inherited := s.withFolderDefaults(h)
+			args := append(sshOptions(h, inherited), hostName)
+
+			cmd := *exec.Command(strings.ToLower(command), args...)
 
 			if len(h.Proxy) > 0 {
 				tool := "nc"
@@ -2372,14 +2617,15 @@ func (s *AllConfigs) Connect(chosen string) error {
 					}
 				}
 				if len(h.Password) > 0 {
-					cmd = *exec.Command("sshpass", "-p", h.Password, strings.ToLower(command), "-o", "StrictHostKeyChecking=no", hostName)
+					cmd = *exec.Command("sshpass", append([]string{"-p", h.Password, strings.ToLower(command), "-o", "StrictHostKeyChecking=no"}, args...)...)
 				}
 			}
 
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			cmd.Run()
+			if err := writeConnection(hostName); err != nil {
+				log.Println(err)
+			}
+
+			runAttached(&cmd)
 		}
 	case "console":
 
@@ -2399,10 +2645,7 @@ func (s *AllConfigs) Connect(chosen string) error {
 		if strings.ToLower(command) == "connect via cu" {
 
 			cmd := *exec.Command("sudo", "cu", "-s", consoleProfile.BaudRate, "-l", consoleProfile.Device)
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			cmd.Run()
+			runAttached(&cmd)
 
 		} else if strings.ToLower(command) == "duplicate/edit profile" {
 
@@ -2422,12 +2665,13 @@ func (s *AllConfigs) Connect(chosen string) error {
 	return nil
 }
 
-func (s *AllConfigs) removefromDatabase(hostname string) error {
+func (s *AllConfigs) removefromDatabase(hostnames ...string) error {
 
 	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
 	stmt, err := tx.Prepare("DELETE FROM sshprofiles WHERE host = ?;")
 	if err != nil {
@@ -2435,22 +2679,32 @@ func (s *AllConfigs) removefromDatabase(hostname string) error {
 	}
 	defer stmt.Close()
 
-	// Execute the DELETE statement with the provided hostname.
-	result, err := stmt.Exec(hostname)
-	if err != nil {
-		return fmt.Errorf("failed to delete record for host '%s': %w", hostname, err)
-	}
+	for _, hostname := range hostnames {
+		// Execute the DELETE statement with the provided hostname.
+		result, err := stmt.Exec(hostname)
+		if err != nil {
+			return fmt.Errorf("failed to delete record for host '%s': %w", hostname, err)
+		}
 
-	// Check how many rows were affected by the delete operation.
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected after deletion: %w", err)
+		// Check how many rows were affected by the delete operation.
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected after deletion: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			fmt.Printf("ℹ️ No record found for host in the database'%s'.\n", hostname)
+		} else {
+			fmt.Printf("🗑️ Successfully deleted %d record(s) '%s'.\n", rowsAffected, hostname)
+		}
+
+		if _, err := tx.Exec("DELETE FROM host_tags WHERE host = ?;", hostname); err != nil {
+			return fmt.Errorf("failed to delete the tags of host '%s': %w", hostname, err)
+		}
 	}
 
-	if rowsAffected == 0 {
-		fmt.Printf("ℹ️ No record found for host in the database'%s'.\n", hostname)
-	} else {
-		fmt.Printf("🗑️ Successfully deleted %d record(s) '%s'.\n", rowsAffected, hostname)
+	if _, err := tx.Exec(dropUnusedTags); err != nil {
+		return fmt.Errorf("failed to delete the unused tags: %w", err)
 	}
 
 	return tx.Commit()
@@ -2569,19 +2823,90 @@ func editConsoleProfile(config ConsoleConfig) error {
 	return nil
 }
 
+// errGoBack is returned by Connect when the user leaves the submenu with (b).
+var errGoBack = errors.New("back to the list")
+
+// InitUi runs the menu, starting in folder, until the user quits it. When an
+// action is done the list it was started from is shown again with the cursor
+// on the same entry, unless the exit_after_connect setting is on.
 func (s *AllConfigs) InitUi(folder string) error {
 
-	items_to_show := s.getItems(folder)
-	chosen, err := main_ui(items_to_show, msg, false)
-	if err != nil {
-		return err
-	}
+	exitAfterConnect := readBoolSetting("exit_after_connect")
+	cursorOn := ""
+	message := msg
 
-	if err := s.navigateToNext(chosen); err != nil {
-		return err
-	}
+	for {
+		// The tags may have changed since the last menu, the list and the search use them
+		tags, err := readAllHostTags()
+		if err != nil {
+			log.Println(err)
+		}
+		hostTags = tags
 
-	return nil
+		items_to_show := s.getItems(folder)
+
+		// The notes may have changed since the last menu, index them again when needed
+		searchHosts = s
+		fullIndex = nil
+		previews = map[string][]string{}
+		previewFolder = folder
+		if folder == "" {
+			s.previewSmartFolders()
+		}
+		if isSmartFolder(folder) {
+			folders, err := readSmartFolders()
+			if err != nil {
+				log.Println(err)
+			}
+			message += fmt.Sprintf("%s %s%s%s: %s%s%s\n\n", smartFolderIcon, magenta, smartFolderName(folder), reset, blue, folders[smartFolderName(folder)], reset)
+		} else if folder != "" {
+			message += fmt.Sprintf("%s %s%s%s\n\n", folderIcon, magenta, folder, reset)
+		}
+		chosen, selected, err := main_ui_at(items_to_show, message, cursorOn)
+		if err != nil {
+			return err
+		}
+		message = msg
+
+		var (
+			next  string
+			acted bool
+		)
+		if len(selected) > 0 {
+			next, acted, err = folder, true, s.bulkAction(selected)
+			if errors.Is(err, errGoBack) {
+				acted, err = false, nil
+			}
+		} else {
+			next, acted, err = s.navigateToNext(chosen, folder)
+		}
+		if exitAfterConnect && (acted || err != nil) {
+			return err
+		}
+
+		// Leaving a prompt of the action goes back to the list
+		if err != nil && !errors.Is(err, errQuit) {
+			log.Println(err)
+			message = fmt.Sprintf("%s [!] %v%s\n\n", red, err, reset) + msg
+		}
+
+		switch next {
+		case folder:
+			cursorOn = menuKey(strings.TrimPrefix(chosen, toggleFavoritePrefix))
+		case parentFolder(folder):
+			cursorOn = folderBase(folder)
+		default:
+			cursorOn = ""
+		}
+		folder = next
+
+		// The action may have changed the ssh config
+		if fresh, err := getHosts(); err == nil {
+			*s = *fresh
+		} else {
+			log.Println(err)
+		}
+	}
 }
 
 func main() {
@@ -2649,6 +2974,13 @@ func main() {
 		return
 	}
 
+	// sshcli get/put copy files without the UI, for scripts
+	if args := flag.Args(); len(args) > 0 && (args[0] == "get" || args[0] == "put") {
+		code := runTransferCli(args)
+		db.Close()
+		os.Exit(code)
+	}
+
 	allHosts, err := getHosts()
 
 	if *action != "" {
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// previewNoteLines is how much of the note the preview shows.
+const previewNoteLines = 3
+
+var (
+	// previews holds the preview lines of the hosts by alias, so the database is
+	// read once per host and menu. InitUi empties it.
+	previews = map[string][]string{}
+
+	// previewFolder is the folder the menu shows, the folder lines are its subfolders
+	previewFolder string
+)
+
+// previewLines describes the entry under the cursor: the config block of a
+// host with what the database knows about it, or the size of a folder.
+func previewLines(choice string) []string {
+	alias := hostAlias(choice)
+	if alias == "" || searchHosts == nil {
+		if folder := folderName(choice); folder != "" && searchHosts != nil {
+			count := searchHosts.countHostsIn(subFolderPath(previewFolder, folder))
+			hosts := "hosts"
+			if count == 1 {
+				hosts = "host"
+			}
+			return []string{fmt.Sprintf("%s %s%s%s: %d %s", folderIcon, magenta, folder, reset, count, hosts)}
+		}
+		if line := stripAnsi(choice); isSmartFolder(line) {
+			return previews[line]
+		}
+		return nil
+	}
+
+	if lines, ok := previews[alias]; ok {
+		return lines
+	}
+
+	h := searchHosts.extractHost(alias)
+	if h == nil {
+		return nil
+	}
+
+	var lines []string
+	for i, line := range h.configBlock() {
+		// Secure mode only shows the keywords, not what they're set to
+		if keyword, _, ok := strings.Cut(strings.TrimSpace(line), " "); ok && isSecure && i > 0 {
+			line = "    " + keyword + " " + private
+		}
+		lines = append(lines, line)
+	}
+
+	// What the host gets from its folder, see withFolderDefaults
+	effective, inherited := searchHosts.withFolderDefaults(h)
+	for _, key := range folderDefaultKeys {
+		from, ok := inherited[key]
+		if !ok {
+			continue
+		}
+		value := defaultValue(effective, key)
+		if isSecure || key == "Password" {
+			value = private
+		}
+		lines = append(lines, fmt.Sprintf("    %s %s  %s(from the folder %s)%s", key, value, blue, from, reset))
+	}
+	lines = append(lines, "")
+
+	folder := h.Folder
+	if folder == "" {
+		folder = "-"
+	}
+	lines = append(lines, fmt.Sprintf("%sFolder:%s %s", blue, reset, folder))
+
+	if tags := hostTags[alias]; len(tags) > 0 {
+		lines = append(lines, fmt.Sprintf("%sTags:%s %s", blue, reset, strings.Join(tags, ", ")))
+	}
+
+	if url, err := searchHosts.readUrlFromDb(alias); err == nil && url != "" {
+		if isSecure {
+			url = private
+		}
+		lines = append(lines, fmt.Sprintf("%sURL:%s %s", blue, reset, url))
+	}
+
+	lastConnected, count, err := readConnections(alias)
+	if err != nil {
+		log.Println(err)
+	}
+	switch {
+	case lastConnected == "":
+		lastConnected = "never"
+	case count == 1:
+		lastConnected += " (1 connection)"
+	case count > 1:
+		lastConnected += fmt.Sprintf(" (%d connections)", count)
+	}
+	lines = append(lines, fmt.Sprintf("%sLast connected:%s %s", blue, reset, lastConnected))
+
+	note, err := searchHosts.readNoteforHost(alias, true)
+	if err != nil {
+		log.Printf("failed to read the note of %s for the preview: %v", alias, err)
+	}
+	var noteLines []string
+	for _, line := range strings.Split(note, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			noteLines = append(noteLines, line)
+		}
+	}
+	switch {
+	case len(noteLines) == 0:
+	case isSecure:
+		lines = append(lines, fmt.Sprintf("%sNote:%s %s (%d lines)", blue, reset, private, len(noteLines)))
+	default:
+		lines = append(lines, fmt.Sprintf("%sNote:%s", blue, reset))
+		for _, line := range noteLines[:min(len(noteLines), previewNoteLines)] {
+			lines = append(lines, "    "+line)
+		}
+		if len(noteLines) > previewNoteLines {
+			lines = append(lines, fmt.Sprintf("    … %d more lines", len(noteLines)-previewNoteLines))
+		}
+	}
+
+	previews[alias] = lines
+	return lines
+}
+
+// previewSmartFolders fills the previews of the smart folders with their query
+// and how many hosts match it. Finding the hosts goes through getItems, so
+// InitUi does it before the menu is shown rather than the preview while it's
+// drawn.
+func (s *AllConfigs) previewSmartFolders() {
+	folders, err := readSmartFolders()
+	if err != nil {
+		log.Println(err)
+	}
+	if len(folders) == 0 {
+		return
+	}
+
+	lines := s.hostLines()
+	for name, query := range folders {
+		matched, _ := rankChoices(lines, query, func(choice string) string { return choice }, false)
+		count := len(matched)
+		hosts := "hosts"
+		if count == 1 {
+			hosts = "host"
+		}
+
+		previews[smartFolderIcon+" "+name] = []string{fmt.Sprintf("%s %s%s%s: %s%s%s, %d %s", smartFolderIcon, magenta, name, reset, blue, query, reset, count, hosts)}
+	}
+}
+
+// folderName returns the folder of a folder line of the menu, or "".
+func folderName(choice string) string {
+	line := strings.TrimSpace(stripAnsi(choice))
+	if name, ok := strings.CutPrefix(line, folderIcon); ok {
+		return strings.TrimSpace(name)
+	}
+	return ""
+}
+
+// countHostsIn counts the hosts of the folder and its subfolders.
+func (s *AllConfigs) countHostsIn(folder string) int {
+	count := 0
+	for _, h := range *s {
+		if h.Folder == folder || strings.HasPrefix(h.Folder, folder+"/") {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchField is a piece of a host the / search looks at. weight puts matches
+// on the alias before matches on, say, the URL.
+type searchField struct {
+	name   string
+	text   string
+	weight int
+}
+
+// searchMatch is how a choice matched the query: positions are the matched
+// runes of the visible menu line, or of the field when it isn't on the line.
+type searchMatch struct {
+	score     int
+	field     searchField
+	positions []int
+	onLine    bool
+}
+
+var (
+	// searchIndex holds the fields of the hosts by alias, getItems fills it
+	searchIndex = map[string][]searchField{}
+
+	// searchHosts is the host list of the menu. The fields only searched when
+	// the search scope is everything are indexed from it the first time
+	// they're needed: the decrypted notes and the other ssh config lines, by
+	// alias, and the menu lines of the hosts of every folder.
+	searchHosts    *AllConfigs
+	fullIndex      map[string][]searchField
+	fullIndexLines []string
+)
+
+// indexHost stores what the search should find the host by.
+func indexHost(h SSHConfig, url string) {
+	fields := []searchField{{name: "alias", text: h.Host, weight: 30}}
+
+	// Secure mode hides these from the list, they're not searched either
+	if !isSecure {
+		fields = append(fields,
+			searchField{name: "hostname", text: h.HostName, weight: 20},
+			searchField{name: "user", text: h.User, weight: 10},
+			searchField{name: "url", text: url})
+	}
+
+	fields = append(fields,
+		searchField{name: "folder", text: h.Folder, weight: 10},
+		searchField{name: "tags", text: strings.Join(hostTags[h.Host], ", "), weight: 10})
+
+	searchIndex[h.Host] = fields
+}
+
+// hostAlias returns the alias of a host line of the menu, or "".
+func hostAlias(choice string) string {
+	line := stripAnsi(choice)
+	if !strings.HasPrefix(line, sshIcon+" ") || strings.Contains(line, "New SSH Profile") {
+		return ""
+	}
+
+	if parts := strings.Fields(line); len(parts) > 1 {
+		return parts[1]
+	}
+	return ""
+}
+
+// searchFieldsFor returns the fields of a menu line. Lines that aren't hosts
+// are searched as they're shown.
+func searchFieldsFor(choice, shown string, all bool) []searchField {
+	alias := hostAlias(choice)
+	fields, ok := searchIndex[alias]
+	if alias == "" || !ok {
+		return []searchField{{name: "line", text: shown}}
+	}
+
+	if all {
+		fields = append(slices.Clone(fields), fullIndex[alias]...)
+	}
+	return fields
+}
+
+// buildFullIndex indexes what the everything scope searches, see searchHosts.
+func buildFullIndex() {
+	fullIndex = map[string][]searchField{}
+	fullIndexLines = nil
+	if searchHosts == nil {
+		return
+	}
+
+	s := searchHosts
+	fullIndexLines = s.hostLines()
+
+	// The notes and the config lines show up as the snippet of the match,
+	// secure mode doesn't search them
+	if isSecure {
+		return
+	}
+
+	for _, h := range *s {
+		var fields []searchField
+
+		note, err := s.readNoteforHost(h.Host, true)
+		if err != nil {
+			log.Printf("failed to read the note of %s for the search: %v", h.Host, err)
+		}
+		for _, line := range strings.Split(note, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				fields = append(fields, searchField{name: "note", text: line})
+			}
+		}
+
+		for _, attrib := range h.OtherAttribs {
+			if attrib = strings.TrimSpace(attrib); attrib != "" {
+				fields = append(fields, searchField{name: "config", text: attrib})
+			}
+		}
+
+		fullIndex[h.Host] = fields
+	}
+}
+
+// hostLines returns the menu lines of the hosts of every folder.
+func (s *AllConfigs) hostLines() []string {
+	folders := []string{""}
+	if folderhost, err := s.getFolderList(); err == nil {
+		for _, f := range folderhost {
+			if f != "NULL" && f != "" && !slices.Contains(folders, f) {
+				folders = append(folders, f)
+			}
+		}
+	} else {
+		log.Println("Error getting folder list:", err)
+	}
+
+	var lines []string
+	for _, f := range folders {
+		for _, item := range s.getItems(f) {
+			if hostAlias(item) != "" {
+				lines = append(lines, item)
+			}
+		}
+	}
+	return lines
+}
+
+// otherHostLines returns the host lines of the other folders, the ones not
+// in choices.
+func otherHostLines(choices []string) []string {
+	if fullIndex == nil {
+		buildFullIndex()
+	}
+
+	shown := map[string]bool{}
+	for _, choice := range choices {
+		shown[hostAlias(choice)] = true
+	}
+
+	var lines []string
+	for _, line := range fullIndexLines {
+		if alias := hostAlias(line); !shown[alias] {
+			shown[alias] = true
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// matchChoice finds the best matching field of the choice for the query.
+// shown is the line as the menu shows it, the positions point into it.
+func matchChoice(choice, shown, query string, all bool) (searchMatch, bool) {
+	line := []rune(stripAnsi(shown))
+
+	best, found := searchMatch{}, false
+	for _, field := range searchFieldsFor(choice, stripAnsi(shown), all) {
+		if field.text == "" {
+			continue
+		}
+
+		score, positions, ok := fuzzyMatch(query, field.text)
+		if !ok {
+			continue
+		}
+		score += field.weight
+
+		if found && score <= best.score {
+			continue
+		}
+		best, found = searchMatch{score: score, field: field, positions: positions}, true
+
+		// Point the positions at the menu line when the field is shown there
+		if offset := runeIndex(line, []rune(field.text)); offset >= 0 {
+			for i := range best.positions {
+				best.positions[i] += offset
+			}
+			best.onLine = true
+		}
+	}
+
+	return best, found
+}
+
+// rankChoices keeps the choices matching the query, best first. Equal scores
+// keep the order of the menu. The tag:name words of the query keep the hosts
+// with those tags, see splitQuery.
+func rankChoices(choices []string, query string, display func(string) string, all bool) ([]string, map[string]searchMatch) {
+	query, tags := splitQuery(query)
+
+	matches := map[string]searchMatch{}
+	var ranked []string
+	for _, choice := range choices {
+		// A favorite or recent host is in the list twice, it's found once
+		if _, found := matches[choice]; found {
+			continue
+		}
+
+		// tag:name only keeps the hosts with the tag
+		if len(tags) > 0 && !hasTags(hostAlias(choice), tags) {
+			continue
+		}
+
+		match, ok := matchChoice(choice, display(choice), query, all)
+		if !ok {
+			continue
+		}
+		if query == "" {
+			// Nothing but tags to match, show them after the line
+			match = searchMatch{field: searchField{name: "tags", text: strings.Join(hostTags[hostAlias(choice)], ", ")}}
+		}
+		matches[choice] = match
+		ranked = append(ranked, choice)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return matches[ranked[i]].score > matches[ranked[j]].score
+	})
+
+	return ranked, matches
+}
+
+// fuzzyMatch looks for the runes of pattern in text, in order but not
+// necessarily next to each other, ignoring case. The shortest window that
+// contains them is used; runes next to each other or at the start of a word
+// score more, gaps and late starts score less.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+	original := []rune(text)
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+
+	end, pi := -1, 0
+	for i := range t {
+		if t[i] == p[pi] {
+			if pi++; pi == len(p) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	start := end
+	for i, pi := end, len(p)-1; i >= 0; i-- {
+		if t[i] == p[pi] {
+			if pi--; pi < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	var (
+		score     int
+		positions []int
+		prev      = -2
+	)
+	for i, pi := start, 0; i <= end && pi < len(p); i++ {
+		if t[i] != p[pi] {
+			score--
+			continue
+		}
+
+		points := 16
+		if i == prev+1 {
+			points += 8
+		}
+		if i == 0 || isWordSeparator(original[i-1]) {
+			points += 10
+		}
+		score += points
+		positions = append(positions, i)
+		prev = i
+		pi++
+	}
+
+	return score - min(start, 10), positions, true
+}
+
+// snippet cuts a long text down to about width runes around the match.
+func snippet(text string, positions []int, width int) (string, []int) {
+	runes := []rune(text)
+	if len(runes) <= width || len(positions) == 0 {
+		return text, positions
+	}
+
+	start := max(0, min(positions[0]-width/4, len(runes)-width))
+	end := min(len(runes), start+width)
+
+	var shifted []int
+	prefix := 0
+	if start > 0 {
+		prefix = 1
+	}
+	for _, p := range positions {
+		if p >= start && p < end {
+			shifted = append(shifted, p-start+prefix)
+		}
+	}
+
+	cut := string(runes[start:end])
+	if start > 0 {
+		cut = "…" + cut
+	}
+	if end < len(runes) {
+		cut += "…"
+	}
+	return cut, shifted
+}
+
+func isWordSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(".-_@/:", r)
+}
+
+func runeIndex(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if string(s[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// stripAnsi removes the color codes of a menu line.
+func stripAnsi(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\033' {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// highlight marks the visible runes at the given positions of a colored
+// line, the colors around them are kept.
+func highlight(s string, positions []int) string {
+	if len(positions) == 0 {
+		return s
+	}
+
+	marked := map[int]bool{}
+	for _, p := range positions {
+		marked[p] = true
+	}
+
+	var (
+		b       strings.Builder
+		color   string
+		visible int
+	)
+	for i := 0; i < len(s); {
+		if s[i] == '\033' {
+			j := strings.IndexByte(s[i:], 'm')
+			if j < 0 {
+				b.WriteString(s[i:])
+				break
+			}
+			code := s[i : i+j+1]
+			if code == reset {
+				color = ""
+			} else {
+				color += code
+			}
+			b.WriteString(code)
+			i += j + 1
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if marked[visible] {
+			b.WriteString(BOLD + yellow + "\033[4m" + string(r) + reset + color)
+		} else {
+			b.WriteString(string(r))
+		}
+		visible++
+		i += size
+	}
+
+	return b.String()
+}
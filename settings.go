@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// settingDefaults lists the settings sshcli knows, with their default values.
+var settingDefaults = map[string]string{
+	// exit_after_connect ends sshcli when an action is done instead of going back to the menu
+	"exit_after_connect": "false",
+	// preview shows the details of the host under the cursor below the list
+	"preview": "true",
+	// recent_hosts is how many of the hosts connected to last the main list shows on top
+	"recent_hosts": "5",
+}
+
+// readSetting returns the stored value of the setting, or its default.
+func readSetting(key string) string {
+	var value sql.NullString
+	err := db.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("failed to read the setting %s: %v", key, err)
+	}
+
+	if value.Valid {
+		return value.String
+	}
+	return settingDefaults[key]
+}
+
+func readBoolSetting(key string) bool {
+	value, err := strconv.ParseBool(readSetting(key))
+	if err != nil {
+		log.Printf("the setting %s is not true or false: %v", key, err)
+	}
+	return value
+}
+
+// writeSetting stores a setting given as key=value on the command line.
+func writeSetting(assignment string) error {
+	key, value, ok := strings.Cut(assignment, "=")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !ok {
+		return fmt.Errorf("use -set key=value")
+	}
+
+	def, known := settingDefaults[key]
+	if !known {
+		return fmt.Errorf("unknown setting %q, see -settings", key)
+	}
+	if _, err := strconv.ParseBool(def); err == nil {
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s is either true or false", key)
+		}
+	}
+	if _, err := strconv.Atoi(def); err == nil {
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s is a number", key)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the db transaction for the settings:%w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO settings(key,value) VALUES(?,?) ON CONFLICT(key) DO UPDATE SET value = excluded.value;")
+	if err != nil {
+		return fmt.Errorf("failed to prepare the settings statement: %w", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(key, value); err != nil {
+		return fmt.Errorf("failed to store the setting %s: %w", key, err)
+	}
+
+	return tx.Commit()
+}
+
+// printSettings is the -settings listing of the command line.
+func printSettings() {
+	var keys []string
+	for key := range settingDefaults {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		fmt.Printf("%s=%s\n", key, readSetting(key))
+	}
+}
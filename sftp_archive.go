@@ -0,0 +1,322 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shellQuote quotes s for the remote shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// archiveTransfer copies a folder as a single tar.gz stream over an exec
+// session instead of file by file. tar runs on the server, the local side is
+// packed and extracted by sshcli itself. The checksum is the one of the
+// compressed stream, the size the one of the files in it.
+func archiveTransfer(sourceFS, targetFS *FileSystem, sourcePath, targetPath string, currentProgress *transferProgress) (string, int64, error) {
+	switch {
+	case sourceFS.isRemote && !targetFS.isRemote:
+		return downloadArchive(sourceFS, sourcePath, targetPath, currentProgress)
+	case !sourceFS.isRemote && targetFS.isRemote:
+		return uploadArchive(targetFS, sourcePath, targetPath, currentProgress)
+	}
+
+	return "", 0, fmt.Errorf("archive transfers need the local file system on one side")
+}
+
+// paneCommand runs cmd as the user the pane browses as. On a pane opened
+// with sudo that's through sudo, like the sftp server. The returned stdin is
+// written to the command before its own input: the password, when sudo asks
+// for one. -k makes sudo read it every time, so it never ends up in the
+// archive because of cached credentials.
+func paneCommand(fs *FileSystem, cmd string) (string, string) {
+	switch {
+	case !fs.sudo:
+		return cmd, ""
+	case fs.sudoPassword == "":
+		return "sudo -n sh -c " + shellQuote(cmd), ""
+	}
+	return "sudo -k -S -p '' sh -c " + shellQuote(cmd), fs.sudoPassword + "\n"
+}
+
+// remoteSize asks du for the size of a remote folder. It's only used for the
+// progress, so 0 (unknown) is fine when du isn't there.
+func remoteSize(fs *FileSystem, p string) int64 {
+	_, sshClient := fs.clients()
+	cmd, stdin := paneCommand(fs, "du -sk "+shellQuote(p))
+	out, err := runRemote(sshClient, cmd, stdin)
+	if err != nil {
+		return 0
+	}
+
+	kb, err := strconv.ParseInt(strings.Fields(out + " 0")[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return kb * 1024
+}
+
+func downloadArchive(remoteFS *FileSystem, sourcePath, targetPath string, currentProgress *transferProgress) (string, int64, error) {
+	total := remoteSize(remoteFS, sourcePath)
+
+	_, sshClient := remoteFS.clients()
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open an ssh session: %w", err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get the stdout of the session: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	cmd, stdin := paneCommand(remoteFS, fmt.Sprintf("tar czf - -C %s %s", shellQuote(filepath.Dir(sourcePath)), shellQuote("./"+filepath.Base(sourcePath))))
+	session.Stdin = strings.NewReader(stdin)
+	if err := session.Start(cmd); err != nil {
+		return "", 0, fmt.Errorf("failed to start tar on the server: %w", err)
+	}
+
+	h := sha256.New()
+	gz, err := gzip.NewReader(io.TeeReader(stdout, h))
+	if err != nil {
+		session.Wait()
+		return "", 0, fmt.Errorf("tar failed on the server: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	reader := &progressReader{r: gz, total: total, start: time.Now(), progress: currentProgress}
+	if err := extractTar(tar.NewReader(reader), filepath.Base(sourcePath), targetPath); err != nil {
+		return "", reader.done, err
+	}
+
+	// Read what's left of the stream so tar can exit
+	io.Copy(io.Discard, stdout)
+	if err := session.Wait(); err != nil {
+		return "", reader.done, fmt.Errorf("tar failed on the server: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), reader.done, nil
+}
+
+// extractTar writes the archive of the folder name into targetPath. Entries
+// that would end up outside of it are refused, links pointing outside of it
+// too. The files are written through an os.Root of targetPath, so no entry
+// can reach outside of it through a link either.
+func extractTar(tr *tar.Reader, name, targetPath string) error {
+	if err := os.MkdirAll(targetPath, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", targetPath, err)
+	}
+
+	root, err := os.OpenRoot(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", targetPath, err)
+	}
+	defer root.Close()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read the archive: %w", err)
+		}
+
+		top, rest, _ := strings.Cut(filepath.Clean(filepath.FromSlash(hdr.Name)), string(filepath.Separator))
+		if top != name || (rest != "" && !filepath.IsLocal(rest)) {
+			return fmt.Errorf("unexpected entry in the archive: %s", hdr.Name)
+		}
+		if rest == "" {
+			rest = "."
+		}
+		dest := filepath.Join(targetPath, rest)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(rest, hdr.FileInfo().Mode().Perm()|0700); err != nil {
+				return fmt.Errorf("failed to create %s: %w", dest, err)
+			}
+
+		case tar.TypeReg:
+			if err := root.MkdirAll(filepath.Dir(rest), 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+			}
+
+			// A link the archive put there earlier is replaced, not written through
+			if info, err := root.Lstat(rest); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if err := root.Remove(rest); err != nil {
+					return fmt.Errorf("failed to replace the link %s: %w", dest, err)
+				}
+			}
+
+			file, err := root.OpenFile(rest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", dest, err)
+			}
+
+			if _, err := io.Copy(file, tr); err != nil {
+				file.Close()
+				return fmt.Errorf("failed to write %s: %w", dest, err)
+			}
+
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("failed to close %s: %w", dest, err)
+			}
+
+			root.Chtimes(rest, hdr.ModTime, hdr.ModTime)
+
+		case tar.TypeSymlink:
+			link := filepath.FromSlash(hdr.Linkname)
+			if filepath.IsAbs(link) || !filepath.IsLocal(filepath.Join(filepath.Dir(rest), link)) {
+				return fmt.Errorf("refusing the link %s to %s, it points outside of %s", hdr.Name, hdr.Linkname, targetPath)
+			}
+
+			root.Remove(rest)
+			if err := root.Symlink(hdr.Linkname, rest); err != nil {
+				log.Printf("failed to create the link %s: %v", dest, err)
+			}
+
+		default:
+			log.Printf("skipping %s from the archive, its type isn't supported", hdr.Name)
+		}
+	}
+}
+
+func uploadArchive(remoteFS *FileSystem, sourcePath, targetPath string, currentProgress *transferProgress) (string, int64, error) {
+	var total int64
+	filepath.Walk(sourcePath, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+
+	_, sshClient := remoteFS.clients()
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open an ssh session: %w", err)
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get the stdin of the session: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	parent := shellQuote(filepath.Dir(targetPath))
+	cmd, password := paneCommand(remoteFS, fmt.Sprintf("mkdir -p %s && tar xzf - -C %s", parent, parent))
+	if err := session.Start(cmd); err != nil {
+		return "", 0, fmt.Errorf("failed to start tar on the server: %w", err)
+	}
+	if _, err := io.WriteString(stdin, password); err != nil {
+		stdin.Close()
+		return "", 0, fmt.Errorf("failed to send the sudo password: %w", err)
+	}
+
+	// The archive is packed in a goroutine, the uncompressed stream is what
+	// the progress counts
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(packTar(pw, sourcePath, filepath.Base(targetPath)))
+	}()
+
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(stdin, h))
+	reader := &progressReader{r: pr, total: total, start: time.Now(), progress: currentProgress}
+
+	if _, err := io.Copy(gz, reader); err != nil {
+		pr.CloseWithError(err)
+		stdin.Close()
+		return "", reader.done, fmt.Errorf("failed to send the archive: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if err := gz.Close(); err != nil {
+		stdin.Close()
+		return "", reader.done, fmt.Errorf("failed to send the archive: %w", err)
+	}
+	stdin.Close()
+
+	if err := session.Wait(); err != nil {
+		return "", reader.done, fmt.Errorf("tar failed on the server: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), reader.done, nil
+}
+
+// packTar writes the local folder root into w as a tar archive, with name as
+// the top folder.
+func packTar(w io.Writer, root, name string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&(os.ModeSocket|os.ModeNamedPipe) != 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("can't archive %s: %w", p, err)
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(name, rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
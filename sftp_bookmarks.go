@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func readSftpPath(host string) (string, error) {
+	var p sql.NullString
+
+	err := db.QueryRow("SELECT sftp_path FROM sshprofiles WHERE host = ?", host).Scan(&p)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("read sftp path query failed: %w", err)
+	}
+
+	return p.String, nil
+}
+
+func writeSftpPath(host, p string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the db transaction for sftp path update:%w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO sshprofiles(host,sftp_path) VALUES(?,?) ON CONFLICT(host) DO UPDATE SET sftp_path = excluded.sftp_path;", host, p)
+	if err != nil {
+		return fmt.Errorf("failed to update the sftp path for the host %v: %w", host, err)
+	}
+
+	return tx.Commit()
+}
+
+func readBookmarks(host string) ([]string, error) {
+	rows, err := db.Query("SELECT path FROM sftp_bookmarks WHERE host = ? ORDER BY path", host)
+	if err != nil {
+		return nil, fmt.Errorf("error querying bookmarks: %w", err)
+	}
+	defer rows.Close()
+
+	var bookmarks []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			log.Println("Error scanning bookmark:", err)
+			continue
+		}
+		bookmarks = append(bookmarks, p)
+	}
+
+	return bookmarks, rows.Err()
+}
+
+// toggleBookmark adds the path to the bookmarks of the host, or removes it if
+// it's already there. It reports whether the path is bookmarked afterwards.
+func toggleBookmark(host, p string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin the db transaction for bookmark update:%w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM sftp_bookmarks WHERE host = ? AND path = ?", host, p)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove the bookmark %s: %w", p, err)
+	}
+
+	removed, _ := result.RowsAffected()
+	if removed == 0 {
+		if _, err := tx.Exec("INSERT INTO sftp_bookmarks(host,path) VALUES(?,?)", host, p); err != nil {
+			return false, fmt.Errorf("failed to add the bookmark %s: %w", p, err)
+		}
+	}
+
+	return removed == 0, tx.Commit()
+}
+
+// showBookmarks lists the last visited path and the bookmarks of the pane's
+// host. Enter jumps to the path, x removes the bookmark, Esc closes the list.
+func showBookmarks(app *tview.Application, pages *tview.Pages, fs *FileSystem, jump func(p string)) {
+	back := func() {
+		pages.RemovePage("bookmarks")
+		app.SetFocus(fs.list)
+	}
+
+	bookmarks, err := readBookmarks(fs.hostId)
+	if err != nil {
+		log.Println(err)
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true).SetTitle(fmt.Sprintf(" Bookmarks of %s (Enter: jump, x: remove, Esc: close) ", fs.hostId))
+
+	paths := []string{}
+	if last, err := readSftpPath(fs.hostId); err == nil && last != "" {
+		list.AddItem("⟲ "+tview.Escape(last)+" [gray](last visited)", "", 0, nil)
+		paths = append(paths, last)
+	}
+	for _, b := range bookmarks {
+		list.AddItem("🔖 "+tview.Escape(b), "", 0, nil)
+		paths = append(paths, b)
+	}
+
+	if len(paths) == 0 {
+		showModal(pages, "bookmarks", fmt.Sprintf("No bookmarks for %s yet.\nPress m to bookmark the current folder.", fs.hostId), []string{"OK"}, func(string) { app.SetFocus(fs.list) })
+		return
+	}
+
+	list.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		back()
+		jump(paths[index])
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'q':
+			back()
+			return nil
+		case event.Rune() == 'x' || event.Key() == tcell.KeyDelete:
+			index := list.GetCurrentItem()
+			text, _ := list.GetItemText(index)
+			if !strings.HasPrefix(text, "🔖") {
+				return nil
+			}
+			if _, err := toggleBookmark(fs.hostId, paths[index]); err != nil {
+				log.Println(err)
+				return nil
+			}
+			list.RemoveItem(index)
+			paths = append(paths[:index], paths[index+1:]...)
+			if len(paths) == 0 {
+				back()
+			}
+			return nil
+		}
+		return event
+	})
+
+	pages.AddPage("bookmarks", centered(list, 80, min(len(paths)+2, 20)), true, true)
+	app.SetFocus(list)
+}
+
+// resolvePath turns what the user typed into an absolute path of the pane.
+func (fs *FileSystem) resolvePath(p string) string {
+	p = strings.TrimSpace(p)
+
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home := fs.homeDir
+		if !fs.isRemote {
+			home, _ = os.UserHomeDir()
+		}
+		p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(fs.currentPath, p)
+	}
+
+	return filepath.Clean(p)
+}
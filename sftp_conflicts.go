@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rivo/tview"
+)
+
+// conflictResolver decides what happens when the target of a transfer already
+// exists. It asks the user once per job unless an answer was applied to all
+// the jobs of the batch. Parallel jobs wait for each other's question.
+type conflictResolver struct {
+	app    *tview.Application
+	pages  *tview.Pages
+	mu     sync.Mutex
+	policy string // overwrite, skip, rename or newer; empty means ask
+	origin string // where the policy comes from, shown next to its decisions
+}
+
+var conflictPolicies = []struct{ policy, label string }{
+	{"overwrite", "Overwrite"},
+	{"skip", "Skip"},
+	{"rename", "Rename"},
+	{"newer", "Overwrite if newer"},
+}
+
+func newConflictResolver(app *tview.Application, pages *tview.Pages) *conflictResolver {
+	return &conflictResolver{app: app, pages: pages}
+}
+
+// resolve returns where the file should go, or skip if it shouldn't be
+// transferred at all. note describes the decision for the job's status line
+// and is empty when the target doesn't exist.
+func (r *conflictResolver) resolve(sourceFS, targetFS *FileSystem, sourcePath, targetPath string) (newPath, note string, skip bool) {
+	targetInfo, err := targetFS.stat(targetPath)
+	if err != nil {
+		return targetPath, "", false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sourceInfo, err := sourceFS.stat(sourcePath)
+	if err != nil {
+		// The transfer reports the error
+		return targetPath, "", false
+	}
+
+	policy, suffix := r.policy, ", "+r.origin
+	if policy == "" {
+		var all bool
+		policy, all = r.ask(sourceFS, targetFS, sourcePath, targetPath, sourceInfo, targetInfo)
+		suffix = ""
+		if all {
+			r.policy = policy
+			r.origin = "applied to all"
+		}
+	}
+
+	switch policy {
+	case "overwrite":
+		return targetPath, "target overwritten" + suffix, false
+	case "rename":
+		newPath = freeName(targetFS, targetPath)
+		return newPath, fmt.Sprintf("renamed to %s%s", filepath.Base(newPath), suffix), false
+	case "newer":
+		if sourceInfo.ModTime().After(targetInfo.ModTime()) {
+			return targetPath, "overwrite if newer: overwritten" + suffix, false
+		}
+		return targetPath, "overwrite if newer: the target isn't older" + suffix, true
+	default:
+		return targetPath, "skipped, the target exists" + suffix, true
+	}
+}
+
+// ask shows the conflict dialog and waits for the answer. Esc skips the file.
+func (r *conflictResolver) ask(sourceFS, targetFS *FileSystem, sourcePath, targetPath string, sourceInfo, targetInfo os.FileInfo) (policy string, all bool) {
+	type answer struct {
+		policy string
+		all    bool
+	}
+	answers := make(chan answer, 1)
+
+	r.app.QueueUpdateDraw(func() {
+		focus := r.app.GetFocus()
+		form := tview.NewForm()
+
+		done := func(policy string) {
+			all := form.GetFormItemByLabel("Apply to all").(*tview.Checkbox).IsChecked()
+			r.pages.RemovePage("conflict")
+			r.app.SetFocus(focus)
+			answers <- answer{policy, all}
+		}
+
+		var s strings.Builder
+		fmt.Fprintf(&s, "[yellow]%s[white] already exists.\n\n", tview.Escape(targetPath))
+		fmt.Fprintf(&s, "Source (%s): %s, %s\n", sourceFS.systemType, formatSize(sourceInfo.Size()), sourceInfo.ModTime().Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(&s, "Target (%s): %s, %s", targetFS.systemType, formatSize(targetInfo.Size()), targetInfo.ModTime().Format("2006-01-02 15:04:05"))
+
+		form.AddTextView("", s.String(), 0, 4, true, false)
+		form.AddCheckbox("Apply to all", false, nil)
+		for _, p := range conflictPolicies {
+			form.AddButton(p.label, func() { done(p.policy) })
+		}
+		form.SetCancelFunc(func() { done("skip") })
+		form.SetBorder(true).SetTitle(" The target exists ")
+
+		r.pages.AddPage("conflict", centered(form, 90, 12), true, true)
+		r.app.SetFocus(form)
+	})
+
+	a := <-answers
+	return a.policy, a.all
+}
+
+// freeName finds a name next to p that isn't taken yet, like report_1.txt.
+func freeName(fs *FileSystem, p string) string {
+	ext := filepath.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if _, err := fs.stat(candidate); err != nil {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// showModal puts a modal dialog on top of the pages and calls done with the
+// label of the pressed button once the dialog is closed.
+func showModal(pages *tview.Pages, name, text string, buttons []string, done func(label string)) {
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons(buttons).
+		SetDoneFunc(func(_ int, label string) {
+			pages.RemovePage(name)
+			if done != nil {
+				done(label)
+			}
+		})
+
+	pages.AddPage(name, modal, true, true)
+}
+
+// showTextPage shows a scrollable, read-only text page. Esc or q closes it.
+func showTextPage(app *tview.Application, pages *tview.Pages, name, title, text string, done func()) {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false).
+		SetText(text)
+
+	view.SetBorder(true).SetTitle(title)
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' {
+			pages.RemovePage(name)
+			if done != nil {
+				done()
+			}
+			return nil
+		}
+		return event
+	})
+
+	pages.AddPage(name, view, true, true)
+	app.SetFocus(view)
+}
+
+// centered wraps p so it's drawn in the middle of the screen with the given size.
+func centered(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
+
+// showInput asks the user for a line of text. done is called with the text
+// on Enter, Esc cancels the prompt and calls cancel.
+func showInput(app *tview.Application, pages *tview.Pages, name, title, label, value string, done func(text string), cancel func()) {
+	input := tview.NewInputField().
+		SetLabel(label).
+		SetText(value).
+		SetFieldWidth(0)
+
+	input.SetBorder(true).SetTitle(title)
+	input.SetDoneFunc(func(key tcell.Key) {
+		pages.RemovePage(name)
+		switch key {
+		case tcell.KeyEnter:
+			done(input.GetText())
+		default:
+			if cancel != nil {
+				cancel()
+			}
+		}
+	})
+
+	pages.AddPage(name, centered(input, 80, 3), true, true)
+	app.SetFocus(input)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+type usageEntry struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+// diskUsage computes the size of every child of dir. du over an exec session
+// is much faster, the sftp walk is the fallback when there's no du, no exec,
+// or the pane runs through sudo and du wouldn't see the same files. update
+// gets the results found so far, and the method used.
+func (fs *FileSystem) diskUsage(ctx context.Context, dir string, update func(entries []usageEntry, method string)) error {
+	if !fs.sudo {
+		entries, err := fs.duUsage(ctx, dir)
+		if err == nil {
+			update(entries, "du")
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("du failed on %s, walking with sftp instead: %v", dir, err)
+	}
+
+	children, err := fs.readDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var entries []usageEntry
+	for _, child := range children {
+		entry := usageEntry{name: child.Name(), size: child.Size(), isDir: child.IsDir()}
+		if entry.isDir {
+			entry.size, err = fs.walkSize(ctx, filepath.Join(dir, child.Name()))
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				log.Printf("failed to read %s: %v", filepath.Join(dir, child.Name()), err)
+			}
+		}
+
+		entries = append(entries, entry)
+		update(entries, "sftp walk")
+	}
+
+	return nil
+}
+
+// duUsage runs du on the server. The sizes are the disk usage in KB blocks,
+// like du shows them.
+func (fs *FileSystem) duUsage(ctx context.Context, dir string) ([]usageEntry, error) {
+	_, sshClient := fs.clients()
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	// Closing the session is how the scan is cancelled
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
+
+	script := fmt.Sprintf(`cd %s || exit 2; command -v du >/dev/null || exit 3; du -sk -- * .[!.]* ..?* 2>/dev/null; exit 0`, shellQuote(dir))
+	out, err := session.Output(script)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := fs.readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]bool{}
+	for _, child := range children {
+		dirs[child.Name()] = child.IsDir()
+	}
+
+	var entries []usageEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		size, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, usageEntry{name: name, size: kb * 1024, isDir: dirs[name]})
+	}
+
+	return entries, nil
+}
+
+// walkSize adds up the sizes of the files under dir, without following links.
+func (fs *FileSystem) walkSize(ctx context.Context, dir string) (int64, error) {
+	children, err := fs.readDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, child := range children {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+
+		switch {
+		case child.Mode()&os.ModeSymlink != 0:
+		case child.IsDir():
+			size, _ := fs.walkSize(ctx, filepath.Join(dir, child.Name()))
+			total += size
+		default:
+			total += child.Size()
+		}
+	}
+
+	return total, nil
+}
+
+// showDiskUsage shows the children of the pane's folder sorted by size. Enter
+// goes into a folder, j jumps to the shown folder in the pane, Esc cancels a
+// running scan or closes the view.
+func showDiskUsage(app *tview.Application, pages *tview.Pages, fs *FileSystem, jump func(p string)) {
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true)
+
+	var (
+		dir     = fs.currentPath
+		entries []usageEntry
+		cancel  context.CancelFunc
+		scanId  int
+	)
+
+	back := func() {
+		if cancel != nil {
+			cancel()
+		}
+		pages.RemovePage("du")
+		app.SetFocus(fs.list)
+	}
+
+	render := func(status string) {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].size > entries[j].size })
+
+		var total int64
+		for _, e := range entries {
+			total += e.size
+		}
+
+		esc := "close"
+		if cancel != nil {
+			esc = "cancel"
+		}
+		if status != "" {
+			status += " "
+		}
+		list.SetTitle(fmt.Sprintf(" Disk usage of %s: %s %s(Enter: open, j: jump, Esc: %s) ", tview.Escape(dir), formatSize(total), status, esc))
+
+		current := list.GetCurrentItem()
+		list.Clear()
+		list.AddItem("📁 ..", "", 0, nil)
+
+		const barWidth = 30
+		for _, e := range entries {
+			filled := 0
+			if entries[0].size > 0 {
+				filled = int(e.size * barWidth / entries[0].size)
+			}
+			bar := "[cyan]" + strings.Repeat("█", filled) + "[gray]" + strings.Repeat("░", barWidth-filled) + "[white]"
+
+			icon := "📄"
+			if e.isDir {
+				icon = "📁"
+			}
+			list.AddItem(fmt.Sprintf("%8s %s %s %s", formatSize(e.size), bar, icon, tview.Escape(e.name)), "", 0, nil)
+		}
+		list.SetCurrentItem(current)
+	}
+
+	var scan func(p string)
+	scan = func(p string) {
+		if cancel != nil {
+			cancel()
+		}
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		dir = p
+		entries = nil
+		scanId++
+		id := scanId
+		list.SetCurrentItem(0)
+		render("scanning...")
+
+		go func() {
+			err := fs.diskUsage(ctx, p, func(found []usageEntry, method string) {
+				found = append([]usageEntry(nil), found...)
+				app.QueueUpdateDraw(func() {
+					if id == scanId {
+						entries = found
+						render(fmt.Sprintf("[yellow]scanning with %s...[white]", method))
+					}
+				})
+			})
+
+			app.QueueUpdateDraw(func() {
+				if id != scanId {
+					return
+				}
+				cancelled := ctx.Err() != nil
+				cancel()
+				cancel = nil
+				switch {
+				case cancelled:
+					render("[yellow](cancelled, partial)[white]")
+				case err != nil:
+					log.Printf("disk usage of %s failed: %v", p, err)
+					render(fmt.Sprintf("[red](%v)[white]", err))
+				default:
+					render("")
+				}
+			})
+		}()
+	}
+
+	list.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		if index == 0 {
+			scan(filepath.Dir(dir))
+			return
+		}
+		if e := entries[index-1]; e.isDir {
+			scan(filepath.Join(dir, e.name))
+		}
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			if cancel != nil {
+				cancel()
+				return nil
+			}
+			back()
+			return nil
+		case event.Rune() == 'q':
+			back()
+			return nil
+		case event.Rune() == 'j':
+			p := dir
+			back()
+			jump(p)
+			return nil
+		}
+		return event
+	})
+
+	pages.AddPage("du", list, true, true)
+	app.SetFocus(list)
+	scan(dir)
+}
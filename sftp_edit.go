@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/rivo/tview"
+)
+
+// editSizeLimit is the largest file that's opened in the editor, the whole
+// file is held in memory.
+const editSizeLimit = 10 * 1024 * 1024
+
+// editRemoteFile downloads the remote file to a temp location, suspends the
+// tview app to open it in the user's editor and uploads it back if it changed.
+// If the remote file was modified while editing, the user decides whether to
+// overwrite it or to have a look at the diff first. The transfers run off the
+// event loop, so a slow or lost connection doesn't freeze the TUI.
+func editRemoteFile(app *tview.Application, pages *tview.Pages, fs *FileSystem, filename string) {
+	remotePath := filepath.Join(fs.currentPath, filename)
+
+	back := func() {
+		app.SetFocus(fs.list)
+	}
+
+	// notify shows the text from a goroutine
+	notify := func(text string) {
+		app.QueueUpdateDraw(func() {
+			showModal(pages, "edit", text, []string{"OK"}, func(string) { back() })
+		})
+	}
+
+	go func() {
+		info, err := fs.stat(remotePath)
+		if err != nil {
+			log.Printf("failed to stat remote file %s: %v", remotePath, err)
+			notify(fmt.Sprintf("Can't read %s:\n%v", remotePath, err))
+			return
+		}
+		if info.Size() > editSizeLimit {
+			notify(fmt.Sprintf("%s is too large to be edited (%s, the limit is %s).", remotePath, formatSize(info.Size()), formatSize(editSizeLimit)))
+			return
+		}
+
+		original, err := fs.readRemoteFile(remotePath)
+		if err != nil {
+			log.Printf("failed to download %s: %v", remotePath, err)
+			notify(fmt.Sprintf("Can't download %s:\n%v", remotePath, err))
+			return
+		}
+
+		app.QueueUpdateDraw(func() {
+			edited, ok := runEditor(app, pages, filename, original, back)
+			if ok {
+				go uploadEdited(app, pages, fs, remotePath, info, edited, notify)
+			}
+		})
+	}()
+}
+
+// runEditor opens the content in the user's editor, with the tview app
+// suspended. It returns the edited content, ok is false when there's nothing
+// to upload. It runs on the event loop.
+func runEditor(app *tview.Application, pages *tview.Pages, filename string, original []byte, back func()) ([]byte, bool) {
+	// Keep the original file name as the suffix so the editor can pick the right syntax.
+	tmpfile, err := os.CreateTemp("", "sshcli-edit-*-"+filepath.Base(filename))
+	if err != nil {
+		log.Printf("failed to create temp file: %v", err)
+		return nil, false
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write(original); err != nil {
+		tmpfile.Close()
+		log.Printf("failed to write to the temp file: %v", err)
+		return nil, false
+	}
+
+	if err := tmpfile.Close(); err != nil {
+		log.Printf("failed to close temp file: %v", err)
+		return nil, false
+	}
+
+	// Determine the editor to use
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = getDefaultEditor()
+	}
+
+	var editorErr error
+	app.Suspend(func() {
+		cmd := exec.Command(editor, tmpfile.Name())
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		editorErr = cmd.Run()
+	})
+
+	if editorErr != nil {
+		log.Printf("editor exited with error: %v", editorErr)
+		showModal(pages, "edit", fmt.Sprintf("Editor exited with error:\n%v", editorErr), []string{"OK"}, func(string) { back() })
+		return nil, false
+	}
+
+	edited, err := os.ReadFile(tmpfile.Name())
+	if err != nil {
+		log.Printf("failed to read from temp file: %v", err)
+		return nil, false
+	}
+
+	if bytes.Equal(original, edited) {
+		showModal(pages, "edit", fmt.Sprintf("%s was not modified.", filename), []string{"OK"}, func(string) { back() })
+		return nil, false
+	}
+
+	return edited, true
+}
+
+// uploadEdited writes the edited file back, asking the user first when the
+// remote file changed since it was downloaded (info) or can't be checked. It
+// runs off the event loop, notify shows a text from there.
+func uploadEdited(app *tview.Application, pages *tview.Pages, fs *FileSystem, remotePath string, info os.FileInfo, edited []byte, notify func(text string)) {
+	back := func() {
+		app.SetFocus(fs.list)
+	}
+
+	upload := func() {
+		go func() {
+			if err := fs.writeRemoteFile(remotePath, edited, info); err != nil {
+				log.Printf("failed to upload %s: %v", remotePath, err)
+				notify(fmt.Sprintf("Failed to upload %s:\n%v", remotePath, err))
+				return
+			}
+			app.QueueUpdateDraw(func() {
+				fs.updateList()
+				showModal(pages, "edit", fmt.Sprintf("%s has been uploaded.", remotePath), []string{"OK"}, func(string) { back() })
+			})
+		}()
+	}
+
+	current, err := fs.stat(remotePath)
+	if err != nil {
+		// Gone, or the connection is: either way the user decides
+		log.Printf("failed to stat remote file %s before uploading it: %v", remotePath, err)
+		app.QueueUpdateDraw(func() {
+			showModal(pages, "edit", fmt.Sprintf("Can't check %s on the server:\n%v", remotePath, err), []string{"Overwrite", "Cancel"}, func(label string) {
+				if label == "Overwrite" {
+					upload()
+					return
+				}
+				back()
+			})
+		})
+		return
+	}
+	if current.ModTime().Equal(info.ModTime()) && current.Size() == info.Size() {
+		// The remote file is untouched, nothing to worry about.
+		upload()
+		return
+	}
+
+	filename := filepath.Base(remotePath)
+	var askUser func()
+	askUser = func() {
+		text := fmt.Sprintf("%s has been modified on the server while you were editing it.", remotePath)
+		showModal(pages, "edit", text, []string{"Overwrite", "Show diff", "Cancel"}, func(label string) {
+			switch label {
+			case "Overwrite":
+				upload()
+			case "Show diff":
+				go func() {
+					remote, err := fs.readRemoteFile(remotePath)
+					if err != nil {
+						log.Printf("failed to download %s for diff: %v", remotePath, err)
+						app.QueueUpdateDraw(askUser)
+						return
+					}
+					diff := lineDiff(string(remote), string(edited))
+					app.QueueUpdateDraw(func() {
+						showTextPage(app, pages, "diff", fmt.Sprintf(" %s: [red]-remote[white] / [green]+yours[white] (Esc to go back) ", filename), diff, askUser)
+					})
+				}()
+			default:
+				back()
+			}
+		})
+	}
+	app.QueueUpdateDraw(askUser)
+}
+
+func (fs *FileSystem) readRemoteFile(remotePath string) ([]byte, error) {
+	sftpClient, _ := fs.clients()
+	file, err := sftpClient.Open(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, editSizeLimit+1))
+	if err == nil && len(content) > editSizeLimit {
+		return nil, fmt.Errorf("%s is larger than %s", remotePath, formatSize(editSizeLimit))
+	}
+	return content, err
+}
+
+// writeRemoteFile replaces the remote file with content. It's written to a
+// temp file next to it, which is then renamed over it, so a lost connection
+// doesn't leave the file half written. The temp file gets the mode and, when
+// allowed, the owner of the original (info).
+func (fs *FileSystem) writeRemoteFile(remotePath string, content []byte, info os.FileInfo) error {
+	sftpClient, _ := fs.clients()
+	tmpPath := filepath.Join(filepath.Dir(remotePath), fmt.Sprintf(".%s.sshcli-%d", filepath.Base(remotePath), time.Now().UnixNano()))
+
+	file, err := sftpClient.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		sftpClient.Remove(tmpPath)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		sftpClient.Remove(tmpPath)
+		return err
+	}
+
+	if err := sftpClient.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+		sftpClient.Remove(tmpPath)
+		return err
+	}
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		if err := sftpClient.Chown(tmpPath, int(stat.UID), int(stat.GID)); err != nil {
+			log.Printf("failed to keep the owner of %s: %v", remotePath, err)
+		}
+	}
+
+	if err := sftpClient.PosixRename(tmpPath, remotePath); err != nil {
+		sftpClient.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// lineDiff returns a colored line based diff of a and b, built from their
+// longest common subsequence.
+func lineDiff(a, b string) string {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+
+	// The LCS table grows with len(a)*len(b), so give up on huge files.
+	if len(aLines)*len(bLines) > 4_000_000 {
+		return "[yellow]The files are too large to be compared.[white]"
+	}
+
+	lcs := make([][]int, len(aLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bLines)+1)
+	}
+	for i := len(aLines) - 1; i >= 0; i-- {
+		for j := len(bLines) - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var s strings.Builder
+	i, j := 0, 0
+	for i < len(aLines) || j < len(bLines) {
+		switch {
+		case i < len(aLines) && j < len(bLines) && aLines[i] == bLines[j]:
+			s.WriteString("  " + tview.Escape(aLines[i]) + "\n")
+			i++
+			j++
+		case j < len(bLines) && (i == len(aLines) || lcs[i][j+1] >= lcs[i+1][j]):
+			s.WriteString("[green]+ " + tview.Escape(bLines[j]) + "[white]\n")
+			j++
+		default:
+			s.WriteString("[red]- " + tview.Escape(aLines[i]) + "[white]\n")
+			i++
+		}
+	}
+
+	return s.String()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/sftp"
+	"github.com/rivo/tview"
+)
+
+// previewSize is how much of a file is read for the preview pane.
+const previewSize = 8 * 1024
+
+// preview builds the text of the preview pane for the entry of the directory
+// dir, or for dir itself when there's no entry (the ".." item). It runs off
+// the event loop, so it gets copies of what the list holds instead of an index.
+func (fs *FileSystem) preview(dir string, entry fileEntry, ok bool) string {
+	if !ok {
+		info, err := fs.stat(dir)
+		if err != nil {
+			return fmt.Sprintf("[red]%v[white]", err)
+		}
+		return fs.directorySummary(dir, info)
+	}
+
+	fPath := filepath.Join(dir, entry.name)
+	if strings.Contains(entry.kind, "d") {
+		return fs.directorySummary(fPath, entry.info)
+	}
+
+	return fs.fileSummary(fPath, entry.info)
+}
+
+func (fs *FileSystem) infoHeader(fPath string, info os.FileInfo) string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "[yellow]Path:[white]     %s\n", tview.Escape(fPath))
+	fmt.Fprintf(&s, "[yellow]Mode:[white]     %s\n", info.Mode())
+	fmt.Fprintf(&s, "[yellow]Modified:[white] %s\n", info.ModTime().Format("2006-01-02 15:04:05"))
+
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		fmt.Fprintf(&s, "[yellow]Owner:[white]    %d:%d\n", stat.UID, stat.GID)
+	}
+
+	return s.String()
+}
+
+func (fs *FileSystem) directorySummary(dir string, info os.FileInfo) string {
+	var s strings.Builder
+	s.WriteString(fs.infoHeader(dir, info))
+
+	files, err := fs.readDir(dir)
+	if err != nil {
+		fmt.Fprintf(&s, "\n[red]%v[white]\n", err)
+		return s.String()
+	}
+
+	var dirs, regular, links, hidden int
+	var total int64
+	for _, f := range files {
+		switch {
+		case f.Mode()&os.ModeSymlink != 0:
+			links++
+		case f.IsDir():
+			dirs++
+		default:
+			regular++
+			total += f.Size()
+		}
+		if strings.HasPrefix(f.Name(), ".") {
+			hidden++
+		}
+	}
+
+	fmt.Fprintf(&s, "\n[yellow]Entries:[white]  %d\n", len(files))
+	fmt.Fprintf(&s, "  📁 folders: %d\n", dirs)
+	fmt.Fprintf(&s, "  📄 files:   %d (%s)\n", regular, formatSize(total))
+	fmt.Fprintf(&s, "  🌀 links:   %d\n", links)
+	fmt.Fprintf(&s, "  hidden:     %d\n", hidden)
+
+	return s.String()
+}
+
+func (fs *FileSystem) fileSummary(fPath string, info os.FileInfo) string {
+	var s strings.Builder
+	s.WriteString(fs.infoHeader(fPath, info))
+	fmt.Fprintf(&s, "[yellow]Size:[white]     %s\n\n", formatSize(info.Size()))
+
+	file, err := fs.open(fPath)
+	if err != nil {
+		fmt.Fprintf(&s, "[red]%v[white]\n", err)
+		return s.String()
+	}
+	defer file.Close()
+
+	buf := make([]byte, previewSize)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		fmt.Fprintf(&s, "[red]%v[white]\n", err)
+		return s.String()
+	}
+	buf = buf[:n]
+
+	if isText(buf) {
+		s.WriteString(tview.Escape(string(buf)))
+	} else {
+		s.WriteString(tview.Escape(hex.Dump(buf)))
+	}
+
+	if info.Size() > int64(n) {
+		fmt.Fprintf(&s, "\n[gray]... first %s of %s shown[white]\n", formatSize(int64(n)), formatSize(info.Size()))
+	}
+
+	return s.String()
+}
+
+// isText reports whether buf looks like text. The preview may cut a multi-byte
+// character in half at the end, so the last few bytes are forgiven.
+func isText(buf []byte) bool {
+	if bytes.IndexByte(buf, 0) != -1 {
+		return false
+	}
+
+	for trim := 0; trim < utf8.UTFMax && trim <= len(buf); trim++ {
+		if utf8.Valid(buf[:len(buf)-trim]) {
+			return true
+		}
+	}
+
+	return false
+}
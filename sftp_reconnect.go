@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/rivo/tview"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// keepAliveInterval is how often the server is asked if it's still there,
+	// keepAliveTimeout how long it has to answer before the connection counts as dead
+	keepAliveInterval = 15 * time.Second
+	keepAliveTimeout  = 15 * time.Second
+	// transferRetries is how many times a transfer interrupted by a lost
+	// connection is started again once the connection is back
+	transferRetries = 3
+)
+
+// connection keeps the ssh connection of a remote pane alive and dials it
+// again, with the same credentials, when it dies.
+type connection struct {
+	dial func() (*sftp.Client, *ssh.Client, error)
+
+	mu           sync.Mutex
+	changed      *sync.Cond
+	reconnecting bool
+	attempt      int
+	lastErr      error
+	// generation counts the reconnects, a transfer that fails while it
+	// changes was interrupted by the lost connection
+	generation int
+	closed     bool
+	done       chan struct{}
+}
+
+func newConnection(dial func() (*sftp.Client, *ssh.Client, error)) *connection {
+	c := &connection{dial: dial, done: make(chan struct{})}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// keepAlive watches the connection of the pane until close is called.
+// changed runs on the event loop whenever the state of the connection changes.
+func (c *connection) keepAlive(app *tview.Application, fs *FileSystem, changed func()) {
+	go func() {
+		for {
+			deadSftp, deadSsh := fs.clients()
+			if !c.watch(deadSsh) {
+				return
+			}
+
+			// Close the dead clients right away, so the jobs using them fail
+			// now and wait for the new connection instead of hanging
+			deadSftp.Close()
+			deadSsh.Close()
+
+			c.mu.Lock()
+			c.reconnecting = true
+			c.attempt = 0
+			c.mu.Unlock()
+			app.QueueUpdateDraw(changed)
+
+			sftpClient, sshClient, ok := c.redial(app, changed)
+			if !ok {
+				return
+			}
+
+			restored := make(chan struct{})
+			app.QueueUpdateDraw(func() {
+				defer close(restored)
+
+				c.mu.Lock()
+				closed := c.closed
+				c.mu.Unlock()
+				if closed {
+					sftpClient.Close()
+					sshClient.Close()
+					return
+				}
+
+				fs.setClients(sftpClient, sshClient)
+				if _, err := fs.stat(fs.currentPath); err != nil {
+					log.Printf("%s is gone after reconnecting, going back to %s: %v", fs.currentPath, fs.homeDir, err)
+					fs.currentPath = fs.homeDir
+				}
+				fs.updateList()
+
+				c.mu.Lock()
+				c.reconnecting = false
+				c.lastErr = nil
+				c.generation++
+				c.changed.Broadcast()
+				c.mu.Unlock()
+
+				changed()
+			})
+
+			select {
+			case <-restored:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// watch sends keepalives until the connection dies, then returns true. It
+// returns false when the connection is closed on purpose.
+func (c *connection) watch(client *ssh.Client) bool {
+	dead := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(dead)
+	}()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return false
+
+		case <-dead:
+			log.Printf("the ssh connection to %s was closed", client.RemoteAddr())
+			return true
+
+		case <-ticker.C:
+			if err := sendKeepAlive(client, keepAliveTimeout); err != nil {
+				select {
+				case <-c.done:
+					return false
+				default:
+				}
+				log.Printf("the ssh connection to %s is dead: %v", client.RemoteAddr(), err)
+				return true
+			}
+		}
+	}
+}
+
+// sendKeepAlive asks the server for a reply. Servers that don't know the
+// request answer with a failure, which is as good as a success here.
+func sendKeepAlive(client *ssh.Client, timeout time.Duration) error {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+
+	select {
+	case err := <-reply:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no keepalive reply in %s", timeout)
+	}
+}
+
+// redial dials until it works, waiting longer after every failed attempt.
+func (c *connection) redial(app *tview.Application, changed func()) (*sftp.Client, *ssh.Client, bool) {
+	wait := time.Second
+	for {
+		c.mu.Lock()
+		c.attempt++
+		c.mu.Unlock()
+		app.QueueUpdateDraw(changed)
+
+		sftpClient, sshClient, err := c.dial()
+		if err == nil {
+			return sftpClient, sshClient, true
+		}
+		log.Printf("reconnecting failed: %v", err)
+
+		c.mu.Lock()
+		c.lastErr = err
+		c.mu.Unlock()
+		app.QueueUpdateDraw(changed)
+
+		select {
+		case <-c.done:
+			return nil, nil, false
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, 30*time.Second)
+	}
+}
+
+// status is shown in the status bar, it's empty while the connection is up.
+func (c *connection) status() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.reconnecting {
+		return ""
+	}
+	if c.lastErr != nil {
+		return fmt.Sprintf("reconnecting (attempt %d: %v)", c.attempt, c.lastErr)
+	}
+	return fmt.Sprintf("reconnecting (attempt %d)", c.attempt)
+}
+
+// close stops watching the connection. The clients are closed by the caller.
+func (c *connection) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+		c.changed.Broadcast()
+	}
+}
+
+// clients returns the sftp and ssh clients of the pane. The reconnect swaps
+// them from its own goroutine, so the jobs take them once and use that pair
+// for the whole operation.
+func (fs *FileSystem) clients() (*sftp.Client, *ssh.Client) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.sftpClient, fs.sshClient
+}
+
+func (fs *FileSystem) setClients(sftpClient *sftp.Client, sshClient *ssh.Client) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.sftpClient, fs.sshClient = sftpClient, sshClient
+}
+
+// generation returns how many times the pane's connection was dialed again.
+func (fs *FileSystem) generation() int {
+	if fs.conn == nil {
+		return 0
+	}
+
+	fs.conn.mu.Lock()
+	defer fs.conn.mu.Unlock()
+	return fs.conn.generation
+}
+
+func (fs *FileSystem) reconnecting() bool {
+	return fs.conn != nil && fs.conn.status() != ""
+}
+
+// waitReconnected tells if a failure of the pane's connection was caused by
+// losing it since the given generation. If so, it blocks until the connection
+// is back and returns true.
+func (fs *FileSystem) waitReconnected(generation int) bool {
+	c := fs.conn
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	lost := c.reconnecting || c.generation != generation
+	c.mu.Unlock()
+
+	// The watcher may not have noticed yet
+	_, sshClient := fs.clients()
+	if !lost && sendKeepAlive(sshClient, 5*time.Second) == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for !c.closed && (c.reconnecting || c.generation == generation) {
+		c.changed.Wait()
+	}
+
+	return !c.closed
+}
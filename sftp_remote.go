@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/rivo/tview"
+	"golang.org/x/crypto/ssh"
+)
+
+// loadProfile reads a profile from the ssh config together with the password
+// and passphrase stored for it in the database.
+func loadProfile(hostId string) (*SSHConfig, error) {
+	allHosts, err := getHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	h := allHosts.extractHost(hostId)
+	if h == nil {
+		return nil, fmt.Errorf("can't find the host %s in the ssh config", hostId)
+	}
+
+	if h.Password, err = allHosts.readAndDecryptFromDB(h.Host, "password", true); err != nil {
+		h.Password = ""
+	}
+
+	if h.sshkey_passphrase, err = allHosts.readAndDecryptFromDB(h.Host, "sshkey_passphrase", true); err != nil {
+		h.sshkey_passphrase = ""
+	}
+
+	h, _ = allHosts.withFolderDefaults(h)
+
+	if h.HostName == "" {
+		h.HostName = h.Host
+	}
+
+	if h.Port == "" {
+		h.Port = "22"
+	}
+
+	if strings.HasPrefix(h.IdentityFile, "~") {
+		homeDir, _ := os.UserHomeDir()
+		h.IdentityFile = strings.ReplaceAll(h.IdentityFile, "~", homeDir)
+	}
+
+	return h, nil
+}
+
+func dialProfile(h *SSHConfig) (*sftp.Client, *ssh.Client, error) {
+	return opentheGates(h.HostName+":"+h.Port, h.User, h.IdentityFile, h.Password, h.sshkey_passphrase)
+}
+
+// connectPane turns the pane into the remote file system of the given profile.
+// The previous connection of the pane, if any, is closed. The caller refreshes the list.
+func (fs *FileSystem) connectPane(hostId string) error {
+	h, err := loadProfile(hostId)
+	if err != nil {
+		return err
+	}
+
+	sftpClient, sshClient, err := dialProfile(h)
+	if err != nil {
+		if sshClient != nil {
+			sshClient.Close()
+		}
+		return err
+	}
+
+	fs.disconnectPane()
+
+	fs.isRemote = true
+	fs.setClients(sftpClient, sshClient)
+	fs.hostId = hostId
+	fs.conn = newConnection(func() (*sftp.Client, *ssh.Client, error) {
+		sftpClient, sshClient, err := dialProfile(h)
+		if err != nil && sshClient != nil {
+			sshClient.Close()
+		}
+		return sftpClient, sshClient, err
+	})
+	fs.systemType = fmt.Sprintf("Remote (%s)", hostId)
+	fs.currentPath = "/"
+	fs.homeDir = "/"
+	if wd, err := sftpClient.Getwd(); err == nil {
+		fs.currentPath = wd
+		fs.homeDir = wd
+	}
+
+	return nil
+}
+
+// disconnectPane closes the remote connection of the pane and turns it back
+// into the local file system.
+func (fs *FileSystem) disconnectPane() {
+	if fs.conn != nil {
+		fs.conn.close()
+	}
+	sftpClient, sshClient := fs.clients()
+	if sftpClient != nil {
+		sftpClient.Close()
+	}
+	if sshClient != nil {
+		sshClient.Close()
+	}
+
+	fs.isRemote = false
+	fs.setClients(nil, nil)
+	fs.conn = nil
+	fs.hostId = ""
+	fs.homeDir = ""
+	fs.systemType = getSystemType(false)
+	if wd, err := os.Getwd(); err == nil {
+		fs.currentPath = wd
+	}
+}
+
+// showProfilePicker lets the user choose what the left pane shows: the local
+// file system or the remote file system of another profile. changed is called
+// when the state of the new connection changes.
+func showProfilePicker(app *tview.Application, pages *tview.Pages, fs *FileSystem, changed, done func()) {
+	back := func() {
+		pages.RemovePage("profiles")
+		app.SetFocus(fs.list)
+	}
+
+	allHosts, err := getHosts()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true).SetTitle(" Left pane: choose a file system (Esc: close) ")
+	list.AddItem("💻 Local File System", "", 0, nil)
+
+	hosts := []string{""}
+	for _, h := range *allHosts {
+		if h.Host == "*" {
+			continue
+		}
+		list.AddItem(fmt.Sprintf("%s %s [gray]%s@%s", sshIcon, h.Host, h.User, h.HostName), "", 0, nil)
+		hosts = append(hosts, h.Host)
+	}
+
+	list.SetDoneFunc(back)
+	list.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		back()
+
+		if hosts[index] == "" {
+			fs.disconnectPane()
+			fs.navigateTo(fs.currentPath)
+			done()
+			return
+		}
+
+		showModal(pages, "connecting", fmt.Sprintf("Connecting to %s...", hosts[index]), nil, nil)
+		go func() {
+			err := fs.connectPane(hosts[index])
+			app.QueueUpdateDraw(func() {
+				pages.RemovePage("connecting")
+				if err != nil {
+					log.Printf("failed to open %s in the left pane: %v", hosts[index], err)
+					showModal(pages, "profiles", fmt.Sprintf("Failed to connect to %s:\n%v", hosts[index], err), []string{"OK"}, func(string) { app.SetFocus(fs.list) })
+					return
+				}
+				fs.conn.keepAlive(app, fs, changed)
+				fs.navigateTo(fs.currentPath)
+				app.SetFocus(fs.list)
+				done()
+			})
+		}()
+	})
+
+	pages.AddPage("profiles", centered(list, 80, min(len(hosts)+2, 20)), true, true)
+	app.SetFocus(list)
+}
+
+// transferProgress is the progress of a transfer as "percent^size^rate". The
+// copy writes it while the progress bar reads it from another goroutine.
+type transferProgress struct {
+	mu   sync.Mutex
+	text string
+}
+
+func (p *transferProgress) set(text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.text = text
+}
+
+func (p *transferProgress) get() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.text
+}
+
+// progressReader keeps the transfer progress up to date, in the same
+// "percent^size^rate" format the sftp command's output is parsed into.
+type progressReader struct {
+	r        io.Reader
+	total    int64
+	done     int64
+	start    time.Time
+	progress *transferProgress
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+
+	// The total of an archive is an estimate, don't run past 100%
+	percent := 100
+	if p.total > 0 {
+		percent = min(int(p.done*100/p.total), 100)
+	}
+
+	rate := float64(p.done) / max(time.Since(p.start).Seconds(), 0.001)
+	p.progress.set(fmt.Sprintf("%d%%^%s^%sB/s", percent, formatSize(p.done), formatSize(int64(rate))))
+
+	return n, err
+}
+
+// streamTransfer copies a file between two panes through sshcli itself. It's
+// used when both panes are remote, so the data goes from one sftp connection
+// straight into the other without touching the local disk. It returns the
+// sha256 of the copied data.
+func streamTransfer(sourceFS, targetFS *FileSystem, sourcePath, targetPath string, currentProgress *transferProgress) (string, error) {
+	info, err := sourceFS.stat(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", sourcePath, err)
+	}
+
+	src, err := sourceFS.open(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", sourcePath, err)
+	}
+	defer src.Close()
+
+	dst, err := targetFS.create(targetPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", targetPath, err)
+	}
+
+	h := sha256.New()
+	reader := &progressReader{r: io.TeeReader(src, h), total: info.Size(), start: time.Now(), progress: currentProgress}
+	if _, err := io.Copy(dst, reader); err != nil {
+		dst.Close()
+		return "", fmt.Errorf("failed to copy %s to %s: %w", sourcePath, targetPath, err)
+	}
+
+	if err := dst.Close(); err != nil {
+		return "", fmt.Errorf("failed to close %s: %w", targetPath, err)
+	}
+
+	if err := targetFS.chmod(targetPath, info.Mode().Perm()); err != nil {
+		log.Printf("failed to set the mode of %s: %v", targetPath, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
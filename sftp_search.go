@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// searchLimit stops a search that matches too much.
+const searchLimit = 1000
+
+type searchResult struct {
+	path  string
+	isDir bool
+}
+
+// searchPattern turns what the user typed into a glob. A plain word matches
+// anywhere in the name.
+func searchPattern(query string) string {
+	if strings.ContainsAny(query, "*?[") {
+		return query
+	}
+	return "*" + query + "*"
+}
+
+// searchFiles looks for names matching the glob under dir, case
+// insensitively. find over an exec session is tried first, walking with the
+// sftp client is the fallback. found gets each batch of results.
+func (fs *FileSystem) searchFiles(ctx context.Context, dir, pattern string, found func(results []searchResult)) error {
+	if !fs.sudo {
+		err := fs.findFiles(ctx, dir, pattern, found)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("find failed on %s, walking with sftp instead: %v", dir, err)
+	}
+
+	pattern = strings.ToLower(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	count := 0
+	sftpClient, _ := fs.clients()
+	walker := sftpClient.Walk(dir)
+	for walker.Step() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if walker.Err() != nil || walker.Path() == dir {
+			continue
+		}
+
+		if ok, _ := path.Match(pattern, strings.ToLower(path.Base(walker.Path()))); ok {
+			found([]searchResult{{path: walker.Path(), isDir: walker.Stat().IsDir()}})
+			if count++; count >= searchLimit {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+func (fs *FileSystem) findFiles(ctx context.Context, dir, pattern string, found func(results []searchResult)) error {
+	_, sshClient := fs.clients()
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	// Closing the session is how the search is cancelled
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	// Folders get a trailing slash, printf repeats its format for every argument
+	p := shellQuote(pattern)
+	cmd := fmt.Sprintf(`command -v find >/dev/null || exit 3; find %s -mindepth 1 \( -type d -iname %s -exec printf '%%s/\n' {} + \) -o \( -iname %s -print \) 2>/dev/null; exit 0`, shellQuote(dir), p, p)
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
+
+	count := 0
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && count < searchLimit {
+		line := scanner.Text()
+		found([]searchResult{{path: strings.TrimSuffix(line, "/"), isDir: strings.HasSuffix(line, "/")}})
+		count++
+	}
+
+	if count >= searchLimit {
+		return nil
+	}
+
+	return session.Wait()
+}
+
+// showSearch asks for a name or glob and lists the matches under the pane's
+// folder. Space selects, t transfers the selected files (or the one under the
+// cursor) to the other pane, Enter jumps to the match, Esc cancels a running
+// search or closes the list.
+func showSearch(app *tview.Application, pages *tview.Pages, fs, targetFS *FileSystem, flex_pbar *tview.Flex, jump func(p string)) {
+	showInput(app, pages, "search", fmt.Sprintf(" Search under %s ", fs.currentPath), "Name or glob: ", "", func(query string) {
+		if strings.TrimSpace(query) == "" {
+			app.SetFocus(fs.list)
+			return
+		}
+		showSearchResults(app, pages, fs, targetFS, flex_pbar, jump, fs.currentPath, searchPattern(strings.TrimSpace(query)))
+	}, func() { app.SetFocus(fs.list) })
+}
+
+func showSearchResults(app *tview.Application, pages *tview.Pages, fs, targetFS *FileSystem, flex_pbar *tview.Flex, jump func(p string), dir, pattern string) {
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		results  []searchResult
+		selected = map[int]bool{}
+		status   = "[yellow]searching...[white]"
+	)
+
+	back := func() {
+		cancel()
+		pages.RemovePage("results")
+		app.SetFocus(fs.list)
+	}
+
+	updateTitle := func() {
+		list.SetTitle(fmt.Sprintf(" %s under %s: %d found %s (Space: select, t: transfer, Enter: jump, Esc: close) ", tview.Escape(pattern), tview.Escape(dir), len(results), status))
+	}
+
+	itemText := func(i int) string {
+		r := results[i]
+		rel, _ := filepath.Rel(dir, r.path)
+		mark := "  "
+		if selected[i] {
+			mark = "[green]✓[white] "
+		}
+		if r.isDir {
+			return mark + "📁 " + tview.Escape(rel) + "/"
+		}
+		return mark + "📄 " + tview.Escape(rel)
+	}
+
+	list.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		r := results[index]
+		back()
+		if r.isDir {
+			jump(r.path)
+			return
+		}
+		jump(filepath.Dir(r.path))
+		for i, entry := range fs.entries {
+			if entry.name == filepath.Base(r.path) {
+				fs.list.SetCurrentItem(i + 1)
+				break
+			}
+		}
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'q':
+			back()
+			return nil
+
+		case event.Rune() == ' ':
+			i := list.GetCurrentItem()
+			if i < len(results) && !results[i].isDir {
+				selected[i] = !selected[i]
+				list.SetItemText(i, itemText(i), "")
+			}
+			return nil
+
+		case event.Rune() == 't':
+			var jobs []string
+			for i, r := range results {
+				if selected[i] {
+					jobs = append(jobs, r.path)
+				}
+			}
+			if i := list.GetCurrentItem(); len(jobs) == 0 && i < len(results) && !results[i].isDir {
+				jobs = append(jobs, results[i].path)
+			}
+
+			conflicts := newConflictResolver(app, pages)
+			for i, p := range jobs {
+				bar := newProgressBar()
+				flex_pbar.AddItem(bar, 1, 0, false)
+				go transferPath(fs, targetFS, p, filepath.Join(targetFS.currentPath, filepath.Base(p)), filepath.Base(p), bar, app, flex_pbar, i+1, len(jobs), conflicts)
+			}
+
+			for i := range selected {
+				delete(selected, i)
+				list.SetItemText(i, itemText(i), "")
+			}
+			return nil
+		}
+		return event
+	})
+
+	updateTitle()
+	pages.AddPage("results", list, true, true)
+	app.SetFocus(list)
+
+	go func() {
+		err := fs.searchFiles(ctx, dir, pattern, func(found []searchResult) {
+			app.QueueUpdateDraw(func() {
+				for _, r := range found {
+					results = append(results, r)
+					list.AddItem(itemText(len(results)-1), "", 0, nil)
+				}
+				updateTitle()
+			})
+		})
+
+		app.QueueUpdateDraw(func() {
+			switch {
+			case ctx.Err() != nil:
+				status = "[yellow](cancelled)[white]"
+			case err != nil:
+				log.Printf("search under %s failed: %v", dir, err)
+				status = fmt.Sprintf("[red](%v)[white]", err)
+			case len(results) >= searchLimit:
+				status = fmt.Sprintf("[yellow](stopped at %d)[white]", searchLimit)
+			default:
+				status = ""
+			}
+			updateTitle()
+		})
+	}()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpServerPaths are the usual places of the sftp-server binary. They're
+// tried after the Subsystem line of the server's sshd_config.
+var sftpServerPaths = []string{
+	"/usr/lib/openssh/sftp-server",
+	"/usr/libexec/openssh/sftp-server",
+	"/usr/lib/ssh/sftp-server",
+	"/usr/libexec/sftp-server",
+	"/usr/lib/sftp-server",
+}
+
+// runRemote runs cmd in a new session of the client, feeding it stdin.
+func runRemote(sshClient *ssh.Client, cmd, stdin string) (string, error) {
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to open an ssh session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = strings.NewReader(stdin)
+	out, err := session.CombinedOutput(cmd)
+	return strings.TrimSpace(string(out)), err
+}
+
+// findSftpServer returns the path of the sftp-server binary on the server.
+func findSftpServer(sshClient *ssh.Client) (string, error) {
+	script := `for p in $(awk 'tolower($1) == "subsystem" && $2 == "sftp" {print $3}' /etc/ssh/sshd_config 2>/dev/null) ` +
+		strings.Join(sftpServerPaths, " ") +
+		`; do [ -x "$p" ] && echo "$p" && exit 0; done; exit 1`
+
+	p, err := runRemote(sshClient, script, "")
+	if err != nil || p == "" {
+		return "", fmt.Errorf("can't find the sftp-server binary on the server")
+	}
+
+	return p, nil
+}
+
+// sudoSftpClient starts the sftp-server through sudo in a new session of
+// sshClient, so the returned client works with root privileges. password is
+// given to sudo if it asks for one; without a stored password the user is
+// asked on the terminal. The password that worked is returned, so dialing
+// again doesn't have to ask. It's empty when sudo didn't ask for one.
+func sudoSftpClient(sshClient *ssh.Client, password string) (*sftp.Client, string, error) {
+	server, err := findSftpServer(sshClient)
+	if err != nil {
+		return nil, "", err
+	}
+
+	command := "sudo -n " + server
+	stdin := ""
+	usedPassword := ""
+	if _, err := runRemote(sshClient, "sudo -n true", ""); err != nil {
+		if password == "" {
+			fmt.Print("\n[sudo] password: ")
+			bytePassword, err := term.ReadPassword(uintptr(syscall.Stdin))
+			fmt.Println()
+			if err != nil {
+				return nil, "", fmt.Errorf("error reading the sudo password: %w", err)
+			}
+			password = string(bytePassword)
+		}
+
+		if out, err := runRemote(sshClient, "sudo -k -S -p '' true", password+"\n"); err != nil {
+			return nil, "", fmt.Errorf("sudo failed, wrong password or no sudo rights: %v %s", err, out)
+		}
+
+		// -k makes sudo ask for the password every time, so it never ends up in
+		// the sftp stream because of cached credentials
+		command = "sudo -k -S -p '' " + server
+		stdin = password + "\n"
+		usedPassword = password
+	}
+
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open an ssh session: %w", err)
+	}
+
+	w, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, "", fmt.Errorf("failed to get the stdin of the session: %w", err)
+	}
+
+	r, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, "", fmt.Errorf("failed to get the stdout of the session: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	if err := session.Start(command); err != nil {
+		session.Close()
+		return nil, "", fmt.Errorf("failed to start %s: %w", command, err)
+	}
+
+	if _, err := io.WriteString(w, stdin); err != nil {
+		session.Close()
+		return nil, "", fmt.Errorf("failed to send the sudo password: %w", err)
+	}
+
+	client, err := sftp.NewClientPipe(r, w)
+	if err != nil {
+		session.Close()
+		return nil, "", fmt.Errorf("failed to start the sftp session with sudo: %w %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return client, usedPassword, nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// syncJob is a single step of a sync plan.
+type syncJob struct {
+	kind   string // upload, download, delete or conflict
+	path   string // relative to the synchronized folders
+	reason string
+	mtime  time.Time
+}
+
+type syncPlan struct {
+	sourceFS  *FileSystem
+	targetFS  *FileSystem
+	sourceDir string
+	targetDir string
+	jobs      []syncJob
+	unchanged int
+	dryRun    bool
+}
+
+// showSync asks how the current folders of the panes should be synchronized,
+// compares them and shows the plan. Running the plan adds its jobs to the
+// Transfer Queue, one at a time.
+func showSync(app *tview.Application, pages *tview.Pages, left, right, current *FileSystem, flex_pbar *tview.Flex) {
+	back := func() {
+		pages.RemovePage("sync")
+		app.SetFocus(current.list)
+	}
+
+	directions := []string{
+		fmt.Sprintf("Push: %s → %s", left.systemType, right.systemType),
+		fmt.Sprintf("Pull: %s → %s", right.systemType, left.systemType),
+	}
+	direction := 0
+	if current == right {
+		direction = 1
+	}
+
+	form := tview.NewForm()
+	form.AddDropDown("Direction", directions, direction, func(_ string, index int) { direction = index })
+	form.AddCheckbox("Compare checksums", false, nil)
+	form.AddCheckbox("Delete extra files", false, nil)
+	form.AddCheckbox("Dry run", false, nil)
+	form.AddButton("Compare", func() {
+		checksums := form.GetFormItemByLabel("Compare checksums").(*tview.Checkbox).IsChecked()
+		deleteExtra := form.GetFormItemByLabel("Delete extra files").(*tview.Checkbox).IsChecked()
+		dryRun := form.GetFormItemByLabel("Dry run").(*tview.Checkbox).IsChecked()
+
+		sourceFS, targetFS := left, right
+		if direction == 1 {
+			sourceFS, targetFS = right, left
+		}
+
+		pages.RemovePage("sync")
+		showModal(pages, "comparing", fmt.Sprintf("Comparing %s with %s...", sourceFS.currentPath, targetFS.currentPath), nil, nil)
+
+		go func() {
+			plan, err := buildSyncPlan(sourceFS, targetFS, sourceFS.currentPath, targetFS.currentPath, direction == 0, checksums, deleteExtra)
+			app.QueueUpdateDraw(func() {
+				pages.RemovePage("comparing")
+				if err != nil {
+					log.Printf("failed to compare the folders: %v", err)
+					showModal(pages, "sync", fmt.Sprintf("Failed to compare the folders:\n%v", err), []string{"OK"}, func(string) { app.SetFocus(current.list) })
+					return
+				}
+				plan.dryRun = dryRun
+				showSyncPlan(app, pages, plan, current, flex_pbar)
+			})
+		}()
+	})
+	form.AddButton("Cancel", back)
+	form.SetCancelFunc(back)
+	form.SetBorder(true).SetTitle(" Sync the current folders ")
+
+	pages.AddPage("sync", centered(form, 80, 13), true, true)
+	app.SetFocus(form)
+}
+
+// buildSyncPlan compares the files under sourceDir and targetDir by size and
+// mtime, or by content when checksums is set and the sizes match. Files that
+// are newer on the target side are reported as conflicts and left alone.
+func buildSyncPlan(sourceFS, targetFS *FileSystem, sourceDir, targetDir string, push, checksums, deleteExtra bool) (*syncPlan, error) {
+	sourceFiles, err := sourceFS.walkFiles(sourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", sourceDir, err)
+	}
+
+	targetFiles, err := targetFS.walkFiles(targetDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", targetDir, err)
+	}
+
+	plan := &syncPlan{sourceFS: sourceFS, targetFS: targetFS, sourceDir: sourceDir, targetDir: targetDir}
+
+	copyKind := "download"
+	if push {
+		copyKind = "upload"
+	}
+
+	for rel, src := range sourceFiles {
+		dst, ok := targetFiles[rel]
+		if !ok {
+			plan.jobs = append(plan.jobs, syncJob{kind: copyKind, path: rel, reason: "new", mtime: src.ModTime()})
+			continue
+		}
+
+		var same bool
+		if checksums && src.Size() == dst.Size() {
+			same, err = sameContent(sourceFS, targetFS, filepath.Join(sourceDir, rel), filepath.Join(targetDir, rel))
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			same = src.Size() == dst.Size() && src.ModTime().Truncate(time.Second).Equal(dst.ModTime().Truncate(time.Second))
+		}
+
+		switch {
+		case same:
+			plan.unchanged++
+		case dst.ModTime().Truncate(time.Second).After(src.ModTime().Truncate(time.Second)):
+			plan.jobs = append(plan.jobs, syncJob{kind: "conflict", path: rel, reason: "the target is newer", mtime: src.ModTime()})
+		default:
+			plan.jobs = append(plan.jobs, syncJob{kind: copyKind, path: rel, reason: "changed", mtime: src.ModTime()})
+		}
+	}
+
+	if deleteExtra {
+		for rel := range targetFiles {
+			if _, ok := sourceFiles[rel]; !ok {
+				plan.jobs = append(plan.jobs, syncJob{kind: "delete", path: rel, reason: "not in the source"})
+			}
+		}
+	}
+
+	// Transfers first, then deletions, then the conflicts that are left alone
+	order := map[string]int{copyKind: 0, "delete": 1, "conflict": 2}
+	sort.Slice(plan.jobs, func(i, j int) bool {
+		if order[plan.jobs[i].kind] != order[plan.jobs[j].kind] {
+			return order[plan.jobs[i].kind] < order[plan.jobs[j].kind]
+		}
+		return plan.jobs[i].path < plan.jobs[j].path
+	})
+
+	return plan, nil
+}
+
+// walkFiles lists the regular files under root, keyed by their path relative
+// to root. Links are skipped, they may point anywhere.
+func (fs *FileSystem) walkFiles(root string) (map[string]os.FileInfo, error) {
+	files := map[string]os.FileInfo{}
+
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := fs.readDir(filepath.Join(root, rel))
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			p := filepath.Join(rel, entry.Name())
+			switch {
+			case entry.Mode()&os.ModeSymlink != 0:
+			case entry.IsDir():
+				if err := walk(p); err != nil {
+					return err
+				}
+			case entry.Mode().IsRegular():
+				files[p] = entry
+			}
+		}
+		return nil
+	}
+
+	return files, walk("")
+}
+
+func (fs *FileSystem) checksum(p string) ([]byte, error) {
+	file, err := fs.open(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", p, err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+func sameContent(sourceFS, targetFS *FileSystem, sourcePath, targetPath string) (bool, error) {
+	a, err := sourceFS.checksum(sourcePath)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := targetFS.checksum(targetPath)
+	if err != nil {
+		return false, err
+	}
+
+	return string(a) == string(b), nil
+}
+
+func (plan *syncPlan) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "[yellow]From:[white] %s %s\n", plan.sourceFS.systemType, tview.Escape(plan.sourceDir))
+	fmt.Fprintf(&s, "[yellow]To:[white]   %s %s\n\n", plan.targetFS.systemType, tview.Escape(plan.targetDir))
+
+	counts := map[string]int{}
+	for _, job := range plan.jobs {
+		counts[job.kind]++
+
+		var icon string
+		switch job.kind {
+		case "upload":
+			icon = "[green]⬆ upload  [white]"
+		case "download":
+			icon = "[green]⬇ download[white]"
+		case "delete":
+			icon = "[red]✖ delete  [white]"
+		case "conflict":
+			icon = "[yellow]⚠ conflict[white]"
+		}
+		fmt.Fprintf(&s, "%s %s [gray](%s)[white]\n", icon, tview.Escape(job.path), job.reason)
+	}
+
+	if len(plan.jobs) == 0 {
+		s.WriteString("[green]The folders are in sync.[white]\n")
+	}
+
+	fmt.Fprintf(&s, "\n%d uploads, %d downloads, %d deletions, %d conflicts, %d files up to date\n",
+		counts["upload"], counts["download"], counts["delete"], counts["conflict"], plan.unchanged)
+
+	return s.String()
+}
+
+// showSyncPlan shows what the sync is going to do. Enter runs the plan unless
+// it's a dry run, Esc closes it.
+func showSyncPlan(app *tview.Application, pages *tview.Pages, plan *syncPlan, current *FileSystem, flex_pbar *tview.Flex) {
+	back := func() {
+		pages.RemovePage("syncplan")
+		app.SetFocus(current.list)
+	}
+
+	title := " Sync plan (Enter: run, Esc: cancel) "
+	if plan.dryRun {
+		title = " Sync plan, dry run (Esc: close) "
+	}
+
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false).
+		SetText(plan.String())
+
+	view.SetBorder(true).SetTitle(title)
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'q':
+			back()
+			return nil
+		case event.Key() == tcell.KeyEnter:
+			if !plan.dryRun {
+				back()
+				runSyncPlan(app, plan, flex_pbar)
+			}
+			return nil
+		}
+		return event
+	})
+
+	pages.AddPage("syncplan", view, true, true)
+	app.SetFocus(view)
+}
+
+// runSyncPlan runs the jobs of the plan one after the other in the Transfer
+// Queue. Conflicts are not touched.
+func runSyncPlan(app *tview.Application, plan *syncPlan, flex_pbar *tview.Flex) {
+	var jobs []syncJob
+	for _, job := range plan.jobs {
+		if job.kind != "conflict" {
+			jobs = append(jobs, job)
+		}
+	}
+
+	// The plan already tells which files get overwritten
+	conflicts := &conflictResolver{policy: "overwrite", origin: "sync plan"}
+
+	go func() {
+		for i, job := range jobs {
+			jobNum := i + 1
+			sourcePath := filepath.Join(plan.sourceDir, job.path)
+			targetPath := filepath.Join(plan.targetDir, job.path)
+
+			p := newProgressBar()
+			app.QueueUpdateDraw(func() {
+				flex_pbar.AddItem(p, 1, 0, false)
+			})
+
+			if job.kind == "delete" {
+				text := fmt.Sprintf("  🗑 [%d/%d] Deleted: [%s]", jobNum, len(jobs), job.path)
+				color := tcell.ColorGreen
+				if err := plan.targetFS.remove(targetPath); err != nil {
+					log.Printf("failed to delete %s: %v", targetPath, err)
+					text = fmt.Sprintf("  ❌ [%d/%d] Failed to delete: [%s] %v", jobNum, len(jobs), job.path, err)
+					color = tcell.ColorRed
+				}
+				app.QueueUpdateDraw(func() {
+					p.SetText(text).SetTextColor(color)
+				})
+				if color == tcell.ColorGreen {
+					go func() {
+						time.Sleep(5 * time.Second)
+						app.QueueUpdateDraw(func() {
+							flex_pbar.RemoveItem(p)
+						})
+					}()
+				}
+				continue
+			}
+
+			if err := plan.targetFS.mkdirAll(filepath.Dir(targetPath)); err != nil {
+				log.Printf("failed to create %s: %v", filepath.Dir(targetPath), err)
+				app.QueueUpdateDraw(func() {
+					p.SetText(fmt.Sprintf("  ❌ [%d/%d] Failed: [%s] %v", jobNum, len(jobs), job.path, err)).SetTextColor(tcell.ColorRed)
+				})
+				continue
+			}
+
+			if err := transferPath(plan.sourceFS, plan.targetFS, sourcePath, targetPath, job.path, p, app, flex_pbar, jobNum, len(jobs), conflicts); err != nil {
+				continue
+			}
+
+			// Keep the mtime of the source, the next sync would see a conflict otherwise
+			if err := plan.targetFS.chtimes(targetPath, job.mtime); err != nil {
+				log.Printf("failed to set the mtime of %s: %v", targetPath, err)
+			}
+		}
+
+		app.QueueUpdateDraw(func() {
+			plan.sourceFS.updateList()
+			plan.targetFS.updateList()
+		})
+	}()
+}
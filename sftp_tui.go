@@ -2,16 +2,20 @@ package main
 
 import (
 	"bufio"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -27,12 +31,45 @@ type FileSystem struct {
 	list          *tview.List
 	isRemote      bool
 	sftpClient    *sftp.Client
-	selectedItems map[int]bool
+	sshClient     *ssh.Client
+	selectedItems map[string]bool
 	mu            sync.Mutex
+	// listing is the last read of currentPath, entries is the sorted and filtered
+	// part of it that's shown in the list: entries[i] is the list item i+1 (item 0 is "..")
+	listing    []fileEntry
+	entries    []fileEntry
+	showSize   bool
+	showMtime  bool
+	showMode   bool
+	sortMode   string
+	hideDots   bool
+	filter     string
+	filtering  bool
+	systemType string
+	// hostId is the profile the remote pane belongs to, homeDir its sftp working directory
+	hostId  string
+	homeDir string
+	// sudo is set when the sftp server runs through sudo, the sftp command can't do that.
+	// sudoPassword is what sudo asks for on the server, empty when it doesn't ask
+	sudo         bool
+	sudoPassword string
+	// conn keeps a remote pane connected, it's nil for the local file system
+	conn *connection
 }
 
-func closeAll(c chan os.Signal, app *tview.Application) {
-	<-c
+type fileEntry struct {
+	name string
+	kind string // f, d, lf or ld
+	info os.FileInfo
+}
+
+// closeAll stops the app on ctrl-c, until done is closed.
+func closeAll(c chan os.Signal, done chan struct{}, app *tview.Application) {
+	select {
+	case <-c:
+	case <-done:
+		return
+	}
 	log.Println("\nctrl-c detected!")
 	time.Sleep(3 * time.Second)
 	app.Stop()
@@ -50,15 +87,19 @@ func NewFileSystem(isRemote bool, sftpClient *sftp.Client, sshClient *ssh.Client
 		list:          tview.NewList().ShowSecondaryText(false),
 		isRemote:      isRemote,
 		sftpClient:    sftpClient,
-		selectedItems: make(map[int]bool),
+		sshClient:     sshClient,
+		selectedItems: make(map[string]bool),
+		sortMode:      sortModes[0],
+		systemType:    getSystemType(isRemote),
 	}
 
 	fs.list.SetSelectedTextColor(tcell.ColorGray)
-	fs.list.SetBorder(true).SetTitle(fmt.Sprintf("  %s File System  ", getSystemType(isRemote)))
+	fs.list.SetBorder(true)
+	fs.updateTitle()
 	return fs
 }
 
-func addFileItem(list *tview.List, name string, t string, isSelected bool) {
+func addFileItem(list *tview.List, name string, t string, isSelected bool, details string) {
 	var suffix = ""
 	var icon string
 	var colorTag string
@@ -85,7 +126,56 @@ func addFileItem(list *tview.List, name string, t string, isSelected bool) {
 		suffix = "/"
 	}
 
-	list.AddItem(fmt.Sprintf("%s%s%s %s%s", selectionMark, colorTag, icon, name, suffix), "", 0, nil)
+	if details != "" {
+		details = " [gray]" + details
+	}
+
+	list.AddItem(fmt.Sprintf("%s%s%s %s%s%s", selectionMark, colorTag, icon, name, suffix, details), "", 0, nil)
+}
+
+// detailColumns renders the enabled size, mtime and mode columns of a list item.
+// The name is padded to nameWidth so the columns line up.
+func (fs *FileSystem) detailColumns(entry fileEntry, nameWidth int) string {
+	if !fs.showSize && !fs.showMtime && !fs.showMode {
+		return ""
+	}
+
+	name := entry.name
+	if strings.Contains(entry.kind, "d") {
+		name += "/"
+	}
+
+	columns := []string{strings.Repeat(" ", max(0, nameWidth-len([]rune(name))))}
+	if fs.showSize {
+		size := "-"
+		if entry.kind == "f" {
+			size = formatSize(entry.info.Size())
+		}
+		columns = append(columns, fmt.Sprintf("%8s", size))
+	}
+	if fs.showMtime {
+		columns = append(columns, entry.info.ModTime().Format("2006-01-02 15:04"))
+	}
+	if fs.showMode {
+		columns = append(columns, entry.info.Mode().String())
+	}
+
+	return strings.Join(columns, "  ")
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%c", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
 func getSystemType(isRemote bool) string {
@@ -100,7 +190,8 @@ func (fs *FileSystem) isItFileOrFolder(fPath string, isRemote bool) string {
 	targetPath := ""
 	var targetInfo os.FileInfo
 	if isRemote {
-		targetPath, err = fs.sftpClient.ReadLink(fPath)
+		sftpClient, _ := fs.clients()
+		targetPath, err = sftpClient.ReadLink(fPath)
 		if err != nil {
 			log.Printf("Error reading symlink target: %v\n", err)
 			return "l"
@@ -108,7 +199,7 @@ func (fs *FileSystem) isItFileOrFolder(fPath string, isRemote bool) string {
 		if !filepath.IsAbs(targetPath) {
 			targetPath = filepath.Join(filepath.Dir(fPath), targetPath)
 		}
-		targetInfo, err = fs.sftpClient.Stat(targetPath)
+		targetInfo, err = sftpClient.Stat(targetPath)
 		if err != nil {
 			log.Printf("Error getting target info: %v\n", err)
 			log.Println("The symbolic link may be broken or the target is inaccessible.")
@@ -138,18 +229,115 @@ func (fs *FileSystem) isItFileOrFolder(fPath string, isRemote bool) string {
 	}
 }
 
-func SortedFileInfo(l []os.FileInfo) []os.FileInfo {
-	sort.Slice(l, func(i, j int) bool {
-		return strings.ToLower(l[i].Name()) < strings.ToLower(l[j].Name())
+// sortModes are the orders a pane cycles through, sizes and times are listed largest/newest first.
+var sortModes = []string{"name", "size", "mtime", "ext"}
+
+func SortedFileInfo(l []fileEntry, mode string) []fileEntry {
+	byName := func(a, b fileEntry) bool {
+		return strings.ToLower(a.name) < strings.ToLower(b.name)
+	}
+
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i], l[j]
+		switch mode {
+		case "size":
+			if a.info.Size() != b.info.Size() {
+				return a.info.Size() > b.info.Size()
+			}
+		case "mtime":
+			if !a.info.ModTime().Equal(b.info.ModTime()) {
+				return a.info.ModTime().After(b.info.ModTime())
+			}
+		case "ext":
+			extA, extB := strings.ToLower(filepath.Ext(a.name)), strings.ToLower(filepath.Ext(b.name))
+			if extA != extB {
+				return extA < extB
+			}
+		}
+		return byName(a, b)
 	})
 	return l
 }
 
-func SortedDirEntry(l []os.DirEntry) []os.DirEntry {
-	sort.Slice(l, func(i, j int) bool {
-		return strings.ToLower(l[i].Name()) < strings.ToLower(l[j].Name())
-	})
-	return l
+// readDir lists a directory on the side of the pane, local or remote.
+func (fs *FileSystem) readDir(dir string) ([]os.FileInfo, error) {
+	if fs.isRemote {
+		sftpClient, _ := fs.clients()
+		return sftpClient.ReadDir(dir)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			log.Printf("Error getting file info: %v", err)
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func (fs *FileSystem) stat(p string) (os.FileInfo, error) {
+	if fs.isRemote {
+		sftpClient, _ := fs.clients()
+		return sftpClient.Stat(p)
+	}
+	return os.Stat(p)
+}
+
+func (fs *FileSystem) open(p string) (io.ReadCloser, error) {
+	if fs.isRemote {
+		sftpClient, _ := fs.clients()
+		return sftpClient.Open(p)
+	}
+	return os.Open(p)
+}
+
+func (fs *FileSystem) create(p string) (io.WriteCloser, error) {
+	if fs.isRemote {
+		sftpClient, _ := fs.clients()
+		return sftpClient.Create(p)
+	}
+	return os.Create(p)
+}
+
+func (fs *FileSystem) chmod(p string, mode os.FileMode) error {
+	if fs.isRemote {
+		sftpClient, _ := fs.clients()
+		return sftpClient.Chmod(p, mode)
+	}
+	return os.Chmod(p, mode)
+}
+
+func (fs *FileSystem) chtimes(p string, mtime time.Time) error {
+	if fs.isRemote {
+		sftpClient, _ := fs.clients()
+		return sftpClient.Chtimes(p, mtime, mtime)
+	}
+	return os.Chtimes(p, mtime, mtime)
+}
+
+func (fs *FileSystem) mkdirAll(p string) error {
+	if fs.isRemote {
+		sftpClient, _ := fs.clients()
+		return sftpClient.MkdirAll(p)
+	}
+	return os.MkdirAll(p, 0755)
+}
+
+func (fs *FileSystem) remove(p string) error {
+	if fs.isRemote {
+		sftpClient, _ := fs.clients()
+		return sftpClient.Remove(p)
+	}
+	return os.Remove(p)
 }
 
 func loadingBar(StopChan chan bool) {
@@ -173,152 +361,147 @@ func (fs *FileSystem) updateList() {
 	go loadingBar(StopChan)
 	defer func() { StopChan <- true }()
 
-	t := "d"
-	fs.list.Clear()
-	fs.list.AddItem("📁 ..", "Go to parent directory", 0, nil)
+	fs.listing = nil
 
-	// Track files with their indices
-	itemIndex := 1 // Start from 1 since 0 is ".."
+	files, err := fs.readDir(fs.currentPath)
+	if err != nil {
+		log.Printf("Error reading %s directory: %v", strings.ToLower(getSystemType(fs.isRemote)), err)
+		fs.render()
+		return
+	}
 
-	if fs.isRemote {
-		fileList := make([]os.FileInfo, 0)
-		linkDList := make([]os.FileInfo, 0)
-		linkFList := make([]os.FileInfo, 0)
-		folderList := make([]os.FileInfo, 0)
-		files, err := fs.sftpClient.ReadDir(fs.currentPath)
-		if err != nil {
-			log.Printf("Error reading remote directory: %v", err)
-			return
+	for _, file := range files {
+		t := "d"
+		fPath := filepath.Join(fs.currentPath, file.Name())
+		if file.Mode()&os.ModeSymlink != 0 {
+			t = fs.isItFileOrFolder(fPath, fs.isRemote)
+		} else if !file.IsDir() {
+			t = "f"
 		}
-		for _, file := range files {
-			fPath := filepath.Join(fs.currentPath, file.Name())
-			if file.Mode()&os.ModeSymlink != 0 {
-				t = fs.isItFileOrFolder(fPath, true)
-			} else {
-				if file.IsDir() {
-					t = "d"
-				} else {
-					t = "f"
-				}
-			}
 
-			switch t {
-			case "f":
-				fileList = append(fileList, file)
-			case "d":
-				folderList = append(folderList, file)
-			case "ld":
-				linkDList = append(linkDList, file)
-			case "lf":
-				linkFList = append(linkFList, file)
-			}
+		// Broken links can't be opened nor transferred
+		if t == "l" {
+			continue
 		}
 
-		for _, f := range SortedFileInfo(linkFList) {
-			isSelected := fs.selectedItems[itemIndex]
-			addFileItem(fs.list, f.Name(), "lf", isSelected)
-			itemIndex++
-		}
-		for _, f := range SortedFileInfo(linkDList) {
-			isSelected := fs.selectedItems[itemIndex]
-			addFileItem(fs.list, f.Name(), "ld", isSelected)
-			itemIndex++
+		fs.listing = append(fs.listing, fileEntry{name: file.Name(), kind: t, info: file})
+	}
+
+	fs.render()
+}
+
+// render rebuilds the list from the last directory read, applying the sort
+// mode, the dotfile toggle and the filter. The cursor stays on the same file.
+func (fs *FileSystem) render() {
+	current := ""
+	if entry, ok := fs.entryAt(fs.list.GetCurrentItem()); ok {
+		current = entry.name
+	}
+
+	fs.list.Clear()
+	fs.list.AddItem("📁 ..", "Go to parent directory", 0, nil)
+	fs.entries = nil
+
+	groups := map[string][]fileEntry{}
+	nameWidth := 0
+	filter := strings.ToLower(fs.filter)
+	for _, entry := range fs.listing {
+		if fs.hideDots && strings.HasPrefix(entry.name, ".") {
+			continue
 		}
-		for _, f := range SortedFileInfo(folderList) {
-			isSelected := fs.selectedItems[itemIndex]
-			addFileItem(fs.list, f.Name(), "d", isSelected)
-			itemIndex++
+		if filter != "" && !strings.Contains(strings.ToLower(entry.name), filter) {
+			continue
 		}
-		for _, f := range SortedFileInfo(fileList) {
-			isSelected := fs.selectedItems[itemIndex]
-			addFileItem(fs.list, f.Name(), "f", isSelected)
-			itemIndex++
+
+		groups[entry.kind] = append(groups[entry.kind], entry)
+		nameWidth = max(nameWidth, len([]rune(entry.name))+1)
+	}
+
+	// Links first, then folders and files, same as always
+	for _, t := range []string{"lf", "ld", "d", "f"} {
+		for _, entry := range SortedFileInfo(groups[t], fs.sortMode) {
+			fs.entries = append(fs.entries, entry)
+			addFileItem(fs.list, entry.name, t, fs.isSelected(entry.name), fs.detailColumns(entry, nameWidth))
 		}
-	} else {
-		fileList := make([]os.DirEntry, 0)
-		linkDList := make([]os.DirEntry, 0)
-		linkFList := make([]os.DirEntry, 0)
-		folderList := make([]os.DirEntry, 0)
-		entries, err := os.ReadDir(fs.currentPath)
-		if err != nil {
-			log.Printf("Error reading local directory: %v", err)
-			return
+	}
+
+	for i, entry := range fs.entries {
+		if entry.name == current {
+			fs.list.SetCurrentItem(i + 1)
+			break
 		}
+	}
 
-		for _, entry := range entries {
-			fPath := filepath.Join(fs.currentPath, entry.Name())
-			info, err := entry.Info()
-			if err != nil {
-				log.Printf("Error getting file info: %v", err)
-				continue
-			}
+	fs.updateTitle()
+}
 
-			if info.Mode()&os.ModeSymlink != 0 {
-				t = fs.isItFileOrFolder(fPath, false)
-			} else {
-				if entry.IsDir() {
-					t = "d"
-				} else {
-					t = "f"
-				}
-			}
+func (fs *FileSystem) updateTitle() {
+	title := fmt.Sprintf("  %s File System  ", fs.systemType)
 
-			switch t {
-			case "f":
-				fileList = append(fileList, entry)
-			case "d":
-				folderList = append(folderList, entry)
-			case "ld":
-				linkDList = append(linkDList, entry)
-			case "lf":
-				linkFList = append(linkFList, entry)
-			}
-		}
+	var flags []string
+	if fs.sortMode != sortModes[0] {
+		flags = append(flags, "sort: "+fs.sortMode)
+	}
+	if fs.hideDots {
+		flags = append(flags, "no dotfiles")
+	}
+	if fs.filtering {
+		flags = append(flags, "/"+tview.Escape(fs.filter)+"▏")
+	} else if fs.filter != "" {
+		flags = append(flags, "/"+tview.Escape(fs.filter))
+	}
+	if len(flags) > 0 {
+		title += "[yellow]" + strings.Join(flags, " │ ") + "[white]  "
+	}
 
-		for _, f := range SortedDirEntry(linkFList) {
-			isSelected := fs.selectedItems[itemIndex]
-			addFileItem(fs.list, f.Name(), "lf", isSelected)
-			itemIndex++
-		}
-		for _, f := range SortedDirEntry(linkDList) {
-			isSelected := fs.selectedItems[itemIndex]
-			addFileItem(fs.list, f.Name(), "ld", isSelected)
-			itemIndex++
-		}
-		for _, f := range SortedDirEntry(folderList) {
-			isSelected := fs.selectedItems[itemIndex]
-			addFileItem(fs.list, f.Name(), "d", isSelected)
-			itemIndex++
-		}
-		for _, f := range SortedDirEntry(fileList) {
-			isSelected := fs.selectedItems[itemIndex]
-			addFileItem(fs.list, f.Name(), "f", isSelected)
-			itemIndex++
-		}
+	fs.list.SetTitle(title)
+}
+
+// cycleSort switches the pane to the next sort mode.
+func (fs *FileSystem) cycleSort() {
+	idx := slices.Index(sortModes, fs.sortMode)
+	fs.sortMode = sortModes[(idx+1)%len(sortModes)]
+	fs.render()
+}
+
+// entryAt returns the entry shown at the given list index.
+func (fs *FileSystem) entryAt(index int) (fileEntry, bool) {
+	if index < 1 || index > len(fs.entries) {
+		return fileEntry{}, false
 	}
+	return fs.entries[index-1], true
 }
 
 func (fs *FileSystem) navigateTo(path string) {
 	fs.currentPath = path
+	fs.filter = ""
+	fs.filtering = false
 	fs.clearSelection()
 	fs.updateList()
+	fs.list.SetCurrentItem(0)
 }
 
-func (fs *FileSystem) toggleSelection(index int) {
+func (fs *FileSystem) toggleSelection(name string) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if fs.selectedItems[index] {
-		delete(fs.selectedItems, index)
+	if fs.selectedItems[name] {
+		delete(fs.selectedItems, name)
 	} else {
-		fs.selectedItems[index] = true
+		fs.selectedItems[name] = true
 	}
 }
 
+func (fs *FileSystem) isSelected(name string) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.selectedItems[name]
+}
+
 func (fs *FileSystem) clearSelection() {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
-	fs.selectedItems = make(map[int]bool)
+	fs.selectedItems = make(map[string]bool)
 }
 
 func (fs *FileSystem) getSelectedFiles() []string {
@@ -326,50 +509,13 @@ func (fs *FileSystem) getSelectedFiles() []string {
 	defer fs.mu.Unlock()
 
 	var files []string
-	for idx := range fs.selectedItems {
-		if idx > 0 { // Skip ".." entry
-			text, _ := fs.list.GetItemText(idx)
-			filename := extractFilename(text)
-			if filename != "" {
-				files = append(files, filename)
-			}
-		}
+	for name := range fs.selectedItems {
+		files = append(files, name)
 	}
+	slices.Sort(files)
 	return files
 }
 
-func extractFilename(text string) string {
-	// Remove selection mark, color tags, and icons
-	text = strings.TrimPrefix(text, "✓ ")
-	text = strings.TrimPrefix(text, "  ")
-
-	// Find the position after icons
-	startIdx := 0
-	for i, r := range text {
-		if r == ' ' && i > 0 {
-			startIdx = i + 1
-			break
-		}
-	}
-
-	if startIdx == 0 {
-		return ""
-	}
-
-	filename := text[startIdx:]
-	filename = strings.TrimSuffix(filename, "/")
-
-	// Remove color tags if present
-	if strings.Contains(filename, "[") {
-		parts := strings.Split(filename, " ")
-		if len(parts) > 1 {
-			filename = strings.Join(parts[1:], " ")
-		}
-	}
-
-	return filename
-}
-
 func publicKeyFile(file string, passphrase string) ssh.AuthMethod {
 	buffer, err := os.ReadFile(file)
 	if err != nil {
@@ -424,7 +570,7 @@ func opentheGates(host, user, keyfile, password, passphrase string) (*sftp.Clien
 	return client, conn, nil
 }
 
-func sftpTransfer(remote, localFile, remoteFile, direction string, currentProgress *string) error {
+func sftpTransfer(remote, localFile, remoteFile, direction string, currentProgress *transferProgress) error {
 	cmd := exec.Command("sftp", remote)
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
@@ -471,7 +617,7 @@ func sftpTransfer(remote, localFile, remoteFile, direction string, currentProgre
 			case <-ticker.C:
 				if lastProgress != "" {
 					if strings.Contains(lastProgress, "B/s") {
-						*currentProgress = lastProgress
+						currentProgress.set(lastProgress)
 					}
 
 					if strings.Contains(lastProgress, "100%") {
@@ -497,17 +643,103 @@ func sftpTransfer(remote, localFile, remoteFile, direction string, currentProgre
 	return nil
 }
 
-func transferFile(hostId string, sourceFS, targetFS *FileSystem, filename string, progressBar *tview.TextView, app *tview.Application, flex_pbars *tview.Flex, jobNum, totalJobs int) error {
+func transferFile(sourceFS, targetFS *FileSystem, filename string, progressBar *tview.TextView, app *tview.Application, flex_pbars *tview.Flex, jobNum, totalJobs int, conflicts *conflictResolver) error {
 	sourcePath := filepath.Join(sourceFS.currentPath, filename)
 	targetPath := filepath.Join(targetFS.currentPath, filename)
 
+	return transferPath(sourceFS, targetFS, sourcePath, targetPath, filename, progressBar, app, flex_pbars, jobNum, totalJobs, conflicts)
+}
+
+// transferFunc copies sourcePath to targetPath and keeps currentProgress up to
+// date. It returns the sha256 of the copied data, when it's known, and the
+// number of bytes copied.
+type transferFunc func(sourceFS, targetFS *FileSystem, sourcePath, targetPath string, currentProgress *transferProgress) (string, int64, error)
+
+// transferPath runs a single job of the Transfer Queue. The paths don't have to
+// be in the current folders of the panes, filename is what the job is shown as.
+// conflicts decides what to do when the target exists already.
+func transferPath(sourceFS, targetFS *FileSystem, sourcePath, targetPath, filename string, progressBar *tview.TextView, app *tview.Application, flex_pbars *tview.Flex, jobNum, totalJobs int, conflicts *conflictResolver) error {
+	return runTransferJob(sourceFS, targetFS, sourcePath, targetPath, filename, progressBar, app, flex_pbars, jobNum, totalJobs, conflicts, copyFile)
+}
+
+// copyFile copies a single file with the sftp command, or through sshcli
+// itself when the command can't do it.
+func copyFile(sourceFS, targetFS *FileSystem, sourcePath, targetPath string, currentProgress *transferProgress) (string, int64, error) {
+	info, err := sourceFS.stat(sourcePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to stat %s: %w", sourcePath, err)
+	}
+
+	if (sourceFS.isRemote && targetFS.isRemote) || sourceFS.sudo || targetFS.sudo {
+		checksum, err := streamTransfer(sourceFS, targetFS, sourcePath, targetPath, currentProgress)
+		return checksum, info.Size(), err
+	}
+
+	direction := "put"
+	hostId := targetFS.hostId
+	if sourceFS.isRemote {
+		direction = "get"
+		hostId = sourceFS.hostId
+	}
+
+	if err := sftpTransfer(hostId, sourcePath, targetPath, direction, currentProgress); err != nil {
+		return "", 0, err
+	}
+
+	// The sftp command doesn't report one, so hash the local copy
+	localFS, localPath := sourceFS, sourcePath
+	if sourceFS.isRemote {
+		localFS, localPath = targetFS, targetPath
+	}
+
+	checksum := ""
+	if sum, err := localFS.checksum(localPath); err == nil {
+		checksum = hex.EncodeToString(sum)
+	}
+
+	return checksum, info.Size(), nil
+}
+
+// runTransferJob shows the progress of a Transfer Queue job while transfer runs and
+// records the result in the transfer history.
+func runTransferJob(sourceFS, targetFS *FileSystem, sourcePath, targetPath, filename string, progressBar *tview.TextView, app *tview.Application, flex_pbars *tview.Flex, jobNum, totalJobs int, conflicts *conflictResolver, transfer transferFunc) error {
+	removeLater := func() {
+		// Keep completed progress bars visible for a while before removing
+		go func() {
+			time.Sleep(5 * time.Second)
+			app.QueueUpdateDraw(func() {
+				flex_pbars.RemoveItem(progressBar)
+			})
+		}()
+	}
+
+	app.QueueUpdateDraw(func() {
+		progressBar.SetText(fmt.Sprintf("  [%d/%d] Checking the target: (%s)", jobNum, totalJobs, filename))
+	})
+
+	start := time.Now()
+	targetPath, note, skip := conflicts.resolve(sourceFS, targetFS, sourcePath, targetPath)
+	if skip {
+		logTransfer(sourceFS, targetFS, sourcePath, targetPath, 0, start, "", note)
+		app.QueueUpdateDraw(func() {
+			progressBar.SetText(fmt.Sprintf("  ⏭ [%d/%d] Skipped: [%s] (%s)", jobNum, totalJobs, filename, note))
+			progressBar.SetTextColor(tcell.ColorYellow)
+		})
+		removeLater()
+		return nil
+	}
+
 	var (
-		currentProgress string
+		currentProgress transferProgress
 		spinIndex       = 0
 	)
 
 	updateProgress := func() {
-		updateProgressBar(progressBar, fmt.Sprintf("  [%d/%d] Transferring", jobNum, totalJobs), currentProgress, app, spinIndex, filename)
+		if sourceFS.reconnecting() || targetFS.reconnecting() {
+			updateProgressBar(progressBar, fmt.Sprintf("  [%d/%d] ⏸ Waiting for the connection", jobNum, totalJobs), "", app, spinIndex, filename)
+			return
+		}
+		updateProgressBar(progressBar, fmt.Sprintf("  [%d/%d] Transferring", jobNum, totalJobs), currentProgress.get(), app, spinIndex, filename)
 	}
 
 	ticker := time.NewTicker(100 * time.Millisecond)
@@ -522,37 +754,66 @@ func transferFile(hostId string, sourceFS, targetFS *FileSystem, filename string
 		}
 	}()
 
-	direction := "put"
-	if sourceFS.isRemote {
-		direction = "get"
-	}
 	sourcePath = filepath.Clean(sourcePath)
 	targetPath = filepath.Clean(targetPath)
 
-	err := sftpTransfer(hostId, sourcePath, targetPath, direction, &currentProgress)
+	// A job interrupted by a lost connection starts again once it's back
+	var (
+		checksum string
+		size     int64
+		err      error
+	)
+	for try := 0; ; try++ {
+		sourceGen, targetGen := sourceFS.generation(), targetFS.generation()
+		checksum, size, err = transfer(sourceFS, targetFS, sourcePath, targetPath, &currentProgress)
+		if err == nil || try == transferRetries {
+			break
+		}
+
+		lost := sourceFS.waitReconnected(sourceGen)
+		lost = targetFS.waitReconnected(targetGen) || lost
+		if !lost {
+			break
+		}
+		log.Printf("resuming the transfer of %s after reconnecting: %v", sourcePath, err)
+		currentProgress.set("")
+	}
+	ticker.Stop()
+
+	result := "ok"
+	if err != nil {
+		result = fmt.Sprintf("failed: %v", err)
+	} else if note != "" {
+		result += ", " + note
+	}
+	logTransfer(sourceFS, targetFS, sourcePath, targetPath, size, start, checksum, result)
+
 	if err != nil {
 		log.Println("upload err:", err)
+		app.QueueUpdateDraw(func() {
+			progressBar.Clear()
+			progressBar.SetText(fmt.Sprintf("  ❌ [%d/%d] Failed: [%s] %v", jobNum, totalJobs, filename, err))
+			progressBar.SetTextColor(tcell.ColorRed)
+		})
 		return err
 	}
 
-	ticker.Stop()
-
 	app.QueueUpdateDraw(func() {
 		progressBar.Clear()
-		progressBar.SetText(fmt.Sprintf("  ✅ [%d/%d] Completed: [%s]", jobNum, totalJobs, filename))
+		if note != "" {
+			note = " (" + note + ")"
+		}
+		progressBar.SetText(fmt.Sprintf("  ✅ [%d/%d] Completed: [%s]%s", jobNum, totalJobs, filename, note))
 		progressBar.SetTextColor(tcell.ColorGreen)
 	})
 
-	sourceFS.updateList()
-	targetFS.updateList()
+	// Refresh the panes on the event loop, parallel jobs would mix up the lists otherwise
+	app.QueueUpdateDraw(func() {
+		sourceFS.updateList()
+		targetFS.updateList()
+	})
 
-	// Keep completed progress bars visible for a while before removing
-	go func() {
-		time.Sleep(5 * time.Second)
-		app.QueueUpdateDraw(func() {
-			flex_pbars.RemoveItem(progressBar)
-		})
-	}()
+	removeLater()
 
 	return nil
 }
@@ -606,17 +867,13 @@ func updateProgressBar(progressBar *tview.TextView, message, currentProgress str
 	})
 }
 
-func detectItemType(item string) string {
-	if strings.Contains(item, "🌀📁") {
-		return "ld"
-	}
-	if strings.Contains(item, "🌀📄") {
-		return "lf"
-	}
-	if strings.Contains(item, "📄") {
-		return "f"
-	}
-	return "d"
+// newProgressBar creates the line of a job in the Transfer Queue.
+func newProgressBar() *tview.TextView {
+	return tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWrap(false).
+		SetTextAlign(tview.AlignLeft)
 }
 
 func createLegend() *tview.TextView {
@@ -626,7 +883,9 @@ func createLegend() *tview.TextView {
 		SetWrap(false).
 		SetTextAlign(tview.AlignCenter)
 
-	legendText := `[yellow]【 Keyboard Shortcuts 】[white][cyan]Tab[white]: Switch panes │ [cyan]Space[white]: Select/Deselect │ [cyan]Enter[white]: Open/Transfer │ [cyan]a[white]: Select All │ [cyan]d[white]: Deselect All │ [cyan]t[white]: Transfer Selected │ [cyan]q/Ctrl+C[white]: Quit`
+	legendText := `[yellow]【 Keyboard Shortcuts 】[white][cyan]Tab[white]: Switch panes │ [cyan]Space[white]: Select/Deselect │ [cyan]Enter[white]: Open/Transfer │ [cyan]a[white]: Select All │ [cyan]d[white]: Deselect All │ [cyan]t[white]: Transfer Selected │ [cyan]q/Ctrl+C[white]: Quit
+[cyan]e[white]: Edit remote file │ [cyan]1/2/3[white]: Size/Mtime/Mode columns │ [cyan]v[white]: Preview │ [cyan]s[white]: Sort │ [cyan].[white]: Dotfiles │ [cyan]/[white]: Filter (Esc clears) │ [cyan]g[white]: Go to path │ [cyan]f[white]: Find │ [cyan]p[white]: Tunnels
+[cyan]m[white]: Bookmark │ [cyan]b[white]: Bookmarks │ [cyan]o[white]: Open profile in left pane │ [cyan]y[white]: Sync folders │ [cyan]h[white]: Transfer history │ [cyan]z[white]: Folder as tar.gz │ [cyan]u[white]: Disk usage`
 	legend.SetText(legendText)
 	legend.SetBorder(true).SetBorderPadding(0, 0, 1, 1)
 	return legend
@@ -638,34 +897,97 @@ func createStatusBar(localFS, remoteFS *FileSystem) *tview.TextView {
 		SetRegions(true).
 		SetWrap(false)
 
-	updateStatus := func() {
-		localSelected := len(localFS.getSelectedFiles())
-		remoteSelected := len(remoteFS.getSelectedFiles())
+	status.SetText(statusText(localFS, remoteFS))
+	return status
+}
 
-		statusText := fmt.Sprintf(" [green]Local:[white] %s [yellow](%d selected)[white] │ [blue]Remote:[white] %s [yellow](%d selected)[white] ",
-			localFS.currentPath, localSelected,
-			remoteFS.currentPath, remoteSelected)
+func statusText(localFS, remoteFS *FileSystem) string {
+	localSelected := len(localFS.getSelectedFiles())
+	remoteSelected := len(remoteFS.getSelectedFiles())
 
-		status.SetText(statusText)
-	}
+	return fmt.Sprintf(" [green]%s:[white] %s [yellow](%d selected)[white]%s │ [blue]%s:[white] %s [yellow](%d selected)[white]%s ",
+		localFS.systemType, localFS.currentPath, localSelected, localFS.connectionStatus(),
+		remoteFS.systemType, remoteFS.currentPath, remoteSelected, remoteFS.connectionStatus())
+}
 
-	updateStatus()
-	return status
+func (fs *FileSystem) connectionStatus() string {
+	if fs.conn == nil {
+		return ""
+	}
+	if status := fs.conn.status(); status != "" {
+		return " [red]⟳ " + tview.Escape(status) + "[white]"
+	}
+	return ""
 }
 
-func INIT_SFTP(hostId, host, user, password, port, key, passphrase string) error {
-	sftpClient, sshClient, err := opentheGates(host+":"+port, user, key, password, passphrase)
+func INIT_SFTP(hostId, host, user, password, port, key, passphrase string, sudo bool) error {
+	// dial is used again with the same credentials when the connection dies.
+	// The sudo password asked on the terminal is kept for that too.
+	sudoPassword := password
+	askedPassword := ""
+	dial := func() (*sftp.Client, *ssh.Client, error) {
+		sftpClient, sshClient, err := opentheGates(host+":"+port, user, key, password, passphrase)
+		if err != nil {
+			if sshClient != nil {
+				sshClient.Close()
+			}
+			log.Printf("Failed to create SFTP client: %v\n", err)
+			return nil, nil, err
+		}
+
+		if sudo {
+			sudoClient, usedPassword, err := sudoSftpClient(sshClient, sudoPassword)
+			sftpClient.Close()
+			if err != nil {
+				sshClient.Close()
+				log.Printf("Failed to create the sudo SFTP client: %v\n", err)
+				return nil, nil, err
+			}
+			sftpClient, askedPassword = sudoClient, usedPassword
+			if usedPassword != "" {
+				sudoPassword = usedPassword
+			}
+		}
+
+		return sftpClient, sshClient, nil
+	}
+
+	sftpClient, sshClient, err := dial()
 	if err != nil {
-		log.Printf("Failed to create SFTP client: %v\n", err)
 		return err
 	}
 
-	defer sshClient.Close()
-	defer sftpClient.Close()
-
 	app := tview.NewApplication()
 	localFS := NewFileSystem(false, nil, nil)
 	remoteFS := NewFileSystem(true, sftpClient, sshClient)
+	remoteFS.hostId = hostId
+	remoteFS.conn = newConnection(dial)
+	defer remoteFS.disconnectPane()
+	if sudo {
+		remoteFS.sudo = true
+		remoteFS.sudoPassword = askedPassword
+		remoteFS.systemType = getSystemType(true) + " (sudo)"
+		remoteFS.updateTitle()
+	}
+
+	// Start where a shell would: the home folder on the server and the current folder locally
+	if wd, err := sftpClient.Getwd(); err == nil {
+		remoteFS.currentPath = wd
+		remoteFS.homeDir = wd
+	} else {
+		log.Printf("failed to get the remote working directory: %v", err)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		localFS.currentPath = wd
+	}
+
+	// The tunnels run over the ssh connection of the session and close with it
+	var profile *SSHConfig
+	if allHosts, err := getHosts(); err == nil {
+		profile = allHosts.extractHost(hostId)
+	}
+	tunnels := newTunnelManager(remoteFS, profile)
+	defer tunnels.closeAll()
 
 	flex := tview.NewFlex().
 		AddItem(localFS.list, 0, 1, true).
@@ -684,28 +1006,68 @@ func INIT_SFTP(hostId, host, user, password, port, key, passphrase string) error
 
 	mainFlex := tview.NewFlex().
 		SetDirection(tview.FlexRow).
-		AddItem(legend, 3, 0, false).
+		AddItem(legend, 5, 0, false).
 		AddItem(flex, 0, 5, true).
 		AddItem(flex_pbar, 8, 0, false).
 		AddItem(statusBar, 1, 0, false)
 
+	// Dialogs are shown as pages on top of the main layout
+	pages := tview.NewPages().AddPage("main", mainFlex, true, true)
+
+	// The preview pane is shown next to the file systems when toggled on
+	preview := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	preview.SetBorder(true).SetTitle(" Preview ")
+
+	showPreview := false
+	var previewSeq atomic.Int64
+
+	updatePreview := func(fs *FileSystem, index int) {
+		if !showPreview {
+			return
+		}
+
+		// Reading a remote file takes a while, only the latest request gets drawn.
+		// The entries are replaced on the event loop, the goroutine gets a copy.
+		entry, ok := fs.entryAt(index)
+		dir := fs.currentPath
+		seq := previewSeq.Add(1)
+		go func() {
+			text := fs.preview(dir, entry, ok)
+			app.QueueUpdateDraw(func() {
+				if previewSeq.Load() == seq {
+					preview.SetText(text).ScrollToBeginning()
+				}
+			})
+		}()
+	}
+
+	for _, fs := range []*FileSystem{localFS, remoteFS} {
+		fs.list.SetChangedFunc(func(index int, _, _ string, _ rune) {
+			updatePreview(fs, index)
+		})
+	}
+
 	localFS.updateList()
 	remoteFS.updateList()
 
-	go closeAll(prepareOsSig(), app)
+	// The signals go back to the menu when the TUI returns
+	sig := prepareOsSig()
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(sig)
+		close(done)
+	}()
+	go closeAll(sig, done, app)
 
 	// Function to update status bar
 	updateStatusBar := func() {
-		localSelected := len(localFS.getSelectedFiles())
-		remoteSelected := len(remoteFS.getSelectedFiles())
-
-		statusText := fmt.Sprintf(" [green]Local:[white] %s [yellow](%d selected)[white] │ [blue]Remote:[white] %s [yellow](%d selected)[white] ",
-			localFS.currentPath, localSelected,
-			remoteFS.currentPath, remoteSelected)
-
-		statusBar.SetText(statusText)
+		statusBar.SetText(statusText(localFS, remoteFS))
 	}
 
+	remoteFS.conn.keepAlive(app, remoteFS, updateStatusBar)
+
 	// Function to transfer selected files
 	transferSelectedFiles := func(sourceFS, targetFS *FileSystem) {
 		selectedFiles := sourceFS.getSelectedFiles()
@@ -714,25 +1076,41 @@ func INIT_SFTP(hostId, host, user, password, port, key, passphrase string) error
 		}
 
 		totalJobs := len(selectedFiles)
+		conflicts := newConflictResolver(app, pages)
 		for i, filename := range selectedFiles {
-			p := tview.NewTextView().
-				SetDynamicColors(true).
-				SetRegions(true).
-				SetWrap(false).
-				SetTextAlign(tview.AlignLeft)
-
+			p := newProgressBar()
 			flex_pbar.AddItem(p, 1, 0, false)
 
 			jobNum := i + 1
-			go transferFile(hostId, sourceFS, targetFS, filename, p, app, flex_pbar, jobNum, totalJobs)
+			go transferFile(sourceFS, targetFS, filename, p, app, flex_pbar, jobNum, totalJobs, conflicts)
 		}
 
 		sourceFS.clearSelection()
 		updateStatusBar()
 	}
 
+	// Function to jump to a path typed in or picked from the bookmarks
+	jumpTo := func(fs *FileSystem, p string) {
+		p = fs.resolvePath(p)
+		if info, err := fs.stat(p); err != nil || !info.IsDir() {
+			showModal(pages, "goto", fmt.Sprintf("%s is not a folder.", p), []string{"OK"}, func(string) { app.SetFocus(fs.list) })
+			return
+		}
+		fs.navigateTo(p)
+		updateStatusBar()
+		app.SetFocus(fs.list)
+	}
+
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		currentList := app.GetFocus().(*tview.List)
+		// Leave the keys to the dialog while one is open
+		if name, _ := pages.GetFrontPage(); name != "main" {
+			return event
+		}
+
+		currentList, ok := app.GetFocus().(*tview.List)
+		if !ok {
+			return event
+		}
 		currentFS := localFS
 		targetFS := remoteFS
 
@@ -741,6 +1119,30 @@ func INIT_SFTP(hostId, host, user, password, port, key, passphrase string) error
 			targetFS = localFS
 		}
 
+		// While a filter is being typed, the keys narrow down the pane
+		if currentFS.filtering {
+			switch event.Key() {
+			case tcell.KeyRune:
+				currentFS.filter += string(event.Rune())
+			case tcell.KeyBackspace, tcell.KeyBackspace2:
+				if r := []rune(currentFS.filter); len(r) > 0 {
+					currentFS.filter = string(r[:len(r)-1])
+				}
+			case tcell.KeyEnter, tcell.KeyTab:
+				currentFS.filtering = false
+			case tcell.KeyEscape:
+				currentFS.filtering = false
+				currentFS.filter = ""
+			default:
+				return event
+			}
+			currentFS.render()
+			updatePreview(currentFS, currentList.GetCurrentItem())
+			if event.Key() != tcell.KeyTab {
+				return nil
+			}
+		}
+
 		switch event.Key() {
 		case tcell.KeyTab:
 			if app.GetFocus() == localFS.list {
@@ -748,34 +1150,33 @@ func INIT_SFTP(hostId, host, user, password, port, key, passphrase string) error
 			} else {
 				app.SetFocus(localFS.list)
 			}
+			updatePreview(targetFS, targetFS.list.GetCurrentItem())
 			return nil
 
 		case tcell.KeyRune:
 			switch event.Rune() {
 			case ' ': // Space for selection
 				selectedItem := currentList.GetCurrentItem()
-				if selectedItem > 0 { // Don't select ".."
-					currentFS.toggleSelection(selectedItem)
-					currentFS.updateList()
+				if entry, ok := currentFS.entryAt(selectedItem); ok { // Don't select ".."
+					currentFS.toggleSelection(entry.name)
+					currentFS.render()
 					updateStatusBar()
 				}
 				return nil
 
 			case 'a', 'A': // Select all
-				for i := 1; i < currentList.GetItemCount(); i++ {
-					text, _ := currentList.GetItemText(i)
-					itemType := detectItemType(text)
-					if itemType == "f" || itemType == "lf" { // Only select files
-						currentFS.selectedItems[i] = true
+				for _, entry := range currentFS.entries {
+					if (entry.kind == "f" || entry.kind == "lf") && !currentFS.isSelected(entry.name) { // Only select files
+						currentFS.toggleSelection(entry.name)
 					}
 				}
-				currentFS.updateList()
+				currentFS.render()
 				updateStatusBar()
 				return nil
 
 			case 'd', 'D': // Deselect all
 				currentFS.clearSelection()
-				currentFS.updateList()
+				currentFS.render()
 				updateStatusBar()
 				return nil
 
@@ -783,11 +1184,145 @@ func INIT_SFTP(hostId, host, user, password, port, key, passphrase string) error
 				transferSelectedFiles(currentFS, targetFS)
 				return nil
 
+			case 'e', 'E': // Edit the remote file under the cursor
+				entry, ok := currentFS.entryAt(currentList.GetCurrentItem())
+				if currentFS.isRemote && ok && (entry.kind == "f" || entry.kind == "lf") {
+					editRemoteFile(app, pages, currentFS, entry.name)
+				}
+				return nil
+
+			case '1': // Toggle the size column
+				currentFS.showSize = !currentFS.showSize
+				currentFS.render()
+				return nil
+
+			case '2': // Toggle the mtime column
+				currentFS.showMtime = !currentFS.showMtime
+				currentFS.render()
+				return nil
+
+			case '3': // Toggle the mode column
+				currentFS.showMode = !currentFS.showMode
+				currentFS.render()
+				return nil
+
+			case 'v', 'V': // Toggle the preview pane
+				showPreview = !showPreview
+				if showPreview {
+					flex.AddItem(preview, 0, 1, false)
+					updatePreview(currentFS, currentList.GetCurrentItem())
+				} else {
+					flex.RemoveItem(preview)
+				}
+				return nil
+
+			case 's', 'S': // Cycle the sort mode
+				currentFS.cycleSort()
+				return nil
+
+			case '.': // Hide/show dotfiles
+				currentFS.hideDots = !currentFS.hideDots
+				currentFS.render()
+				return nil
+
+			case '/': // Incremental filter
+				currentFS.filtering = true
+				currentFS.render()
+				return nil
+
+			case 'g', 'G': // Go to path
+				fs := currentFS
+				showInput(app, pages, "goto", " Go to path ", "Path: ", fs.currentPath, func(p string) {
+					jumpTo(fs, p)
+				}, func() { app.SetFocus(fs.list) })
+				return nil
+
+			case 'm', 'M': // Bookmark the current remote folder
+				if currentFS.isRemote {
+					fs := currentFS
+					added, err := toggleBookmark(fs.hostId, fs.currentPath)
+					text := fmt.Sprintf("%s has been bookmarked.", fs.currentPath)
+					if err != nil {
+						log.Println(err)
+						text = fmt.Sprintf("Failed to update the bookmarks:\n%v", err)
+					} else if !added {
+						text = fmt.Sprintf("%s has been removed from the bookmarks.", fs.currentPath)
+					}
+					showModal(pages, "bookmark", text, []string{"OK"}, func(string) { app.SetFocus(fs.list) })
+				}
+				return nil
+
+			case 'b', 'B': // Jump to a bookmark
+				if currentFS.isRemote {
+					fs := currentFS
+					showBookmarks(app, pages, fs, func(p string) { jumpTo(fs, p) })
+				}
+				return nil
+
+			case 'o', 'O': // Open another profile (or the local file system) in the left pane
+				showProfilePicker(app, pages, localFS, updateStatusBar, func() {
+					app.SetFocus(localFS.list)
+					updateStatusBar()
+				})
+				return nil
+
+			case 'y', 'Y': // Sync the current folders
+				showSync(app, pages, localFS, remoteFS, currentFS, flex_pbar)
+				return nil
+
+			case 'z', 'Z': // Download or upload the folder under the cursor as a tar.gz stream
+				entry, ok := currentFS.entryAt(currentList.GetCurrentItem())
+				if !ok || entry.kind != "d" {
+					return nil
+				}
+				if currentFS.isRemote == targetFS.isRemote {
+					fs := currentFS
+					showModal(pages, "archive", "Archive transfers work between the local file system and a remote one.", []string{"OK"}, func(string) { app.SetFocus(fs.list) })
+					return nil
+				}
+				p := newProgressBar()
+				flex_pbar.AddItem(p, 1, 0, false)
+				sourcePath := filepath.Join(currentFS.currentPath, entry.name)
+				targetPath := filepath.Join(targetFS.currentPath, entry.name)
+				go runTransferJob(currentFS, targetFS, sourcePath, targetPath, entry.name+" (tar.gz)", p, app, flex_pbar, 1, 1, newConflictResolver(app, pages), archiveTransfer)
+				return nil
+
+			case 'u', 'U': // Disk usage of the remote folder
+				if currentFS.isRemote {
+					fs := currentFS
+					showDiskUsage(app, pages, fs, func(p string) { jumpTo(fs, p) })
+				}
+				return nil
+
+			case 'f', 'F': // Search by name under the remote folder
+				if currentFS.isRemote {
+					fs := currentFS
+					showSearch(app, pages, fs, targetFS, flex_pbar, func(p string) { jumpTo(fs, p) })
+				}
+				return nil
+
+			case 'p', 'P': // Tunnels over the ssh connection
+				fs := currentFS
+				showTunnels(app, pages, tunnels, func() { app.SetFocus(fs.list) })
+				return nil
+
+			case 'h', 'H': // Transfer history
+				fs := currentFS
+				showTransferHistory(app, pages, func() { app.SetFocus(fs.list) })
+				return nil
+
 			case 'q', 'Q': // Quit
 				app.Stop()
 				return nil
 			}
 
+		case tcell.KeyEscape:
+			if currentFS.filter != "" {
+				currentFS.filter = ""
+				currentFS.render()
+			}
+			return nil
+
 		case tcell.KeyEnter:
 			selectedItem := currentList.GetCurrentItem()
 
@@ -803,10 +1338,9 @@ func INIT_SFTP(hostId, host, user, password, port, key, passphrase string) error
 					parentDir := filepath.Dir(currentFS.currentPath)
 					currentFS.navigateTo(parentDir)
 					updateStatusBar()
-				} else {
-					selectedText, _ := currentList.GetItemText(selectedItem)
-					itemType := detectItemType(selectedText)
-					selectedPath = extractFilename(selectedText)
+				} else if entry, ok := currentFS.entryAt(selectedItem); ok {
+					itemType := entry.kind
+					selectedPath = entry.name
 
 					if strings.Contains(itemType, "d") {
 						newPath := filepath.Join(currentFS.currentPath, selectedPath)
@@ -814,14 +1348,9 @@ func INIT_SFTP(hostId, host, user, password, port, key, passphrase string) error
 						updateStatusBar()
 					} else if itemType == "f" || itemType == "lf" {
 						// Single file transfer
-						p := tview.NewTextView().
-							SetDynamicColors(true).
-							SetRegions(true).
-							SetWrap(false).
-							SetTextAlign(tview.AlignLeft)
-
+						p := newProgressBar()
 						flex_pbar.AddItem(p, 1, 0, false)
-						go transferFile(hostId, currentFS, targetFS, selectedPath, p, app, flex_pbar, 1, 1)
+						go transferFile(currentFS, targetFS, selectedPath, p, app, flex_pbar, 1, 1, newConflictResolver(app, pages))
 					}
 				}
 			}
@@ -830,10 +1359,21 @@ func INIT_SFTP(hostId, host, user, password, port, key, passphrase string) error
 		return event
 	})
 
-	if err := app.SetRoot(mainFlex, true).EnableMouse(true).Run(); err != nil {
+	if err := app.SetRoot(pages, true).EnableMouse(true).Run(); err != nil {
 		log.Printf("Error running application: %v", err)
 		return err
 	}
 
+	for _, fs := range []*FileSystem{localFS, remoteFS} {
+		if fs.isRemote {
+			if err := writeSftpPath(fs.hostId, fs.currentPath); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	// The left pane may hold the connection to another profile
+	localFS.disconnectPane()
+
 	return nil
 }
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// tunnel is a local listener whose connections are carried over the ssh
+// connection of the SFTP session, either to a fixed target (LocalForward) or
+// to wherever a SOCKS5 client asks (DynamicForward).
+type tunnel struct {
+	socks       bool
+	listen      string
+	target      string
+	fromProfile bool
+
+	listener net.Listener
+	err      error
+	conns    atomic.Int64
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// tunnelManager follows the pane, so the tunnels keep working after it
+// reconnects.
+type tunnelManager struct {
+	fs      *FileSystem
+	mu      sync.Mutex
+	tunnels []*tunnel
+}
+
+// newTunnelManager lists the LocalForward and DynamicForward entries of the
+// profile, stopped. They're only started from the tunnels panel.
+func newTunnelManager(fs *FileSystem, h *SSHConfig) *tunnelManager {
+	m := &tunnelManager{fs: fs}
+	if h == nil {
+		return m
+	}
+
+	for _, line := range append(append([]string(nil), h.Sockets...), h.DynamicSocks...) {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && strings.EqualFold(fields[0], "LocalForward"):
+			m.tunnels = append(m.tunnels, &tunnel{listen: listenAddress(fields[1]), target: fields[2], fromProfile: true})
+		case len(fields) == 2 && strings.EqualFold(fields[0], "DynamicForward"):
+			m.tunnels = append(m.tunnels, &tunnel{socks: true, listen: listenAddress(fields[1]), fromProfile: true})
+		}
+	}
+
+	return m
+}
+
+// listenAddress turns the [bind:]port of the ssh config into an address to
+// listen on. Like ssh, a bare port only listens on localhost.
+func listenAddress(s string) string {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return net.JoinHostPort("localhost", s)
+	}
+
+	bind := strings.Trim(s[:i], "[]")
+	if bind == "*" {
+		bind = ""
+	}
+	return net.JoinHostPort(bind, s[i+1:])
+}
+
+func (m *tunnelManager) add(t *tunnel) error {
+	m.mu.Lock()
+	m.tunnels = append(m.tunnels, t)
+	m.mu.Unlock()
+
+	return m.start(t)
+}
+
+func (m *tunnelManager) start(t *tunnel) error {
+	listener, err := net.Listen("tcp", t.listen)
+	if err != nil {
+		t.err = err
+		return fmt.Errorf("failed to listen on %s: %w", t.listen, err)
+	}
+	t.listener, t.err = listener, nil
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(t, conn)
+		}
+	}()
+
+	return nil
+}
+
+func (m *tunnelManager) stop(t *tunnel) {
+	if t.listener != nil {
+		t.listener.Close()
+		t.listener = nil
+	}
+}
+
+// remove stops the tunnel and drops it from the list, unless it comes from
+// the profile.
+func (m *tunnelManager) remove(t *tunnel) {
+	m.stop(t)
+	if t.fromProfile {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, other := range m.tunnels {
+		if other == t {
+			m.tunnels = append(m.tunnels[:i], m.tunnels[i+1:]...)
+			break
+		}
+	}
+}
+
+func (m *tunnelManager) list() []*tunnel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*tunnel(nil), m.tunnels...)
+}
+
+func (m *tunnelManager) closeAll() {
+	for _, t := range m.list() {
+		m.stop(t)
+	}
+}
+
+func (m *tunnelManager) serve(t *tunnel, conn net.Conn) {
+	defer conn.Close()
+
+	target := t.target
+	if t.socks {
+		var err error
+		if target, err = socksHandshake(conn); err != nil {
+			log.Printf("socks request on %s refused: %v", t.listen, err)
+			return
+		}
+	}
+
+	_, sshClient := m.fs.clients()
+	remote, err := sshClient.Dial("tcp", target)
+	if t.socks {
+		// Only success and "general failure" are reported back, the client
+		// doesn't get to learn more about the remote network
+		reply := []byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+		if err != nil {
+			reply[1] = 1
+		}
+		conn.Write(reply)
+	}
+	if err != nil {
+		log.Printf("failed to open %s through the ssh connection: %v", target, err)
+		return
+	}
+	defer remote.Close()
+
+	t.conns.Add(1)
+	defer t.conns.Add(-1)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, io.TeeReader(conn, counter{&t.sent}))
+		remote.Close()
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, io.TeeReader(remote, counter{&t.received}))
+		conn.Close()
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+// counter adds up the bytes written to it.
+type counter struct{ n *atomic.Int64 }
+
+func (c counter) Write(p []byte) (int, error) {
+	c.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// socksHandshake reads a SOCKS5 CONNECT request without authentication and
+// returns the address asked for. The reply is left to the caller.
+func socksHandshake(conn net.Conn) (string, error) {
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != 5 {
+		return "", fmt.Errorf("unsupported socks version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	if !strings.ContainsRune(string(methods), 0) {
+		conn.Write([]byte{5, 0xff})
+		return "", fmt.Errorf("the client needs authentication")
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	if request[1] != 1 {
+		conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0})
+		return "", fmt.Errorf("unsupported socks command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make(net.IP, 4)
+		if request[3] == 4 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case 3:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return "", err
+		}
+		name := make([]byte, size[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+		return "", fmt.Errorf("unsupported socks address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+func (t *tunnel) line() string {
+	kind, target := "L", " → "+tview.Escape(t.target)
+	if t.socks {
+		kind, target = "D", " (SOCKS5)"
+	}
+
+	origin := ""
+	if t.fromProfile {
+		origin = " [gray](profile)[white]"
+	}
+
+	switch {
+	case t.listener != nil:
+		return fmt.Sprintf("[green]●[white] %s %s%s%s  conns %d  ↑ %s  ↓ %s", kind, tview.Escape(t.listen), target, origin,
+			t.conns.Load(), formatSize(t.sent.Load()), formatSize(t.received.Load()))
+	case t.err != nil:
+		return fmt.Sprintf("[red]●[white] %s %s%s%s  [red]%s[white]", kind, tview.Escape(t.listen), target, origin, tview.Escape(t.err.Error()))
+	default:
+		return fmt.Sprintf("[gray]○[white] %s %s%s%s  stopped", kind, tview.Escape(t.listen), target, origin)
+	}
+}
+
+// showTunnels lists the tunnels of the session with their traffic. Enter
+// starts or stops one, n adds a local forward, s a SOCKS5 listener, x removes
+// one. The tunnels keep running when the panel is closed.
+func showTunnels(app *tview.Application, pages *tview.Pages, m *tunnelManager, done func()) {
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true).SetTitle(" Tunnels over this ssh connection (Enter: start/stop, n: local forward, s: SOCKS5, x: remove, Esc: close) ")
+
+	render := func() {
+		current := list.GetCurrentItem()
+		list.Clear()
+		for _, t := range m.list() {
+			list.AddItem(t.line(), "", 0, nil)
+		}
+		if list.GetItemCount() == 0 {
+			list.AddItem("No tunnels yet, n adds a local forward and s a SOCKS5 listener.", "", 0, nil)
+		}
+		list.SetCurrentItem(current)
+	}
+
+	stopRefresh := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				app.QueueUpdateDraw(render)
+			case <-stopRefresh:
+				return
+			}
+		}
+	}()
+
+	selected := func() *tunnel {
+		tunnels := m.list()
+		if i := list.GetCurrentItem(); i < len(tunnels) {
+			return tunnels[i]
+		}
+		return nil
+	}
+
+	back := func() { app.SetFocus(list) }
+
+	failed := func(err error) {
+		log.Println(err)
+		showModal(pages, "tunnel error", err.Error(), []string{"OK"}, func(string) { back() })
+	}
+
+	list.SetSelectedFunc(func(int, string, string, rune) {
+		t := selected()
+		switch {
+		case t == nil:
+		case t.listener != nil:
+			m.stop(t)
+		default:
+			if err := m.start(t); err != nil {
+				failed(err)
+			}
+		}
+		render()
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'q':
+			close(stopRefresh)
+			pages.RemovePage("tunnels")
+			done()
+			return nil
+
+		case event.Rune() == 'n':
+			showInput(app, pages, "new tunnel", " New local forward ", "[bind:]port host:port: ", "", func(text string) {
+				back()
+				fields := strings.Fields(text)
+				if len(fields) != 2 || !isSocketValid("LocalForward "+text) {
+					failed(fmt.Errorf("%q is not a valid forward, use [bind:]port host:port", text))
+					return
+				}
+				if err := m.add(&tunnel{listen: listenAddress(fields[0]), target: fields[1]}); err != nil {
+					failed(err)
+				}
+				render()
+			}, back)
+			return nil
+
+		case event.Rune() == 's':
+			showInput(app, pages, "new tunnel", " New SOCKS5 listener ", "[bind:]port: ", "1080", func(text string) {
+				back()
+				text = strings.TrimSpace(text)
+				if text == "" {
+					return
+				}
+				if err := m.add(&tunnel{socks: true, listen: listenAddress(text)}); err != nil {
+					failed(err)
+				}
+				render()
+			}, back)
+			return nil
+
+		case event.Rune() == 'x':
+			if t := selected(); t != nil {
+				m.remove(t)
+				render()
+			}
+			return nil
+		}
+		return event
+	})
+
+	render()
+	pages.AddPage("tunnels", list, true, true)
+	app.SetFocus(list)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Tags label hosts across folders, a host has any number of them. A search
+// can be saved as a smart folder, shown in the main list next to the folders
+// with the hosts of every folder that match it.
+
+const (
+	smartFolderIcon = "🔎"
+
+	// saveSearchPrefix starts the choice the host list returns when the
+	// search is to be saved, the query follows it.
+	saveSearchPrefix = "💾 "
+
+	removeSmartFolderItem = "🗑️ Remove the smart folder"
+)
+
+// hostTags holds the tags of the hosts by alias, InitUi loads it before every menu.
+var hostTags = map[string][]string{}
+
+// dropUnusedTags removes the tags no host has anymore.
+const dropUnusedTags = "DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM host_tags);"
+
+// readAllHostTags returns the tags of every host, sorted by name.
+func readAllHostTags() (map[string][]string, error) {
+	tags := map[string][]string{}
+
+	rows, err := db.Query("SELECT h.host, t.name FROM host_tags h JOIN tags t ON t.id = h.tag_id ORDER BY t.name")
+	if err != nil {
+		return tags, fmt.Errorf("error querying the tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var host, name string
+		if err := rows.Scan(&host, &name); err != nil {
+			return tags, fmt.Errorf("error scanning the tags: %w", err)
+		}
+		tags[host] = append(tags[host], name)
+	}
+
+	return tags, rows.Err()
+}
+
+// writeHostTags replaces the tags of the host.
+func writeHostTags(host string, tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the db transaction for the tags:%w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM host_tags WHERE host = ?;", host); err != nil {
+		return fmt.Errorf("failed to remove the tags of %s: %w", host, err)
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT INTO tags(name) VALUES(?) ON CONFLICT(name) DO NOTHING;", tag); err != nil {
+			return fmt.Errorf("failed to add the tag %s: %w", tag, err)
+		}
+		if _, err := tx.Exec("INSERT INTO host_tags(host,tag_id) SELECT ?, id FROM tags WHERE name = ?;", host, tag); err != nil {
+			return fmt.Errorf("failed to tag %s with %s: %w", host, tag, err)
+		}
+	}
+
+	if _, err := tx.Exec(dropUnusedTags); err != nil {
+		return fmt.Errorf("failed to remove the unused tags: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// parseTags splits what the user typed into tag names: lower case, without
+// a tag: prefix, each once.
+func parseTags(input string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		tag = strings.ToLower(tag)
+		tag = strings.TrimPrefix(tag, "tag:")
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// setTags asks for the tags of the host. Nothing typed keeps them, a - removes
+// them all.
+func setTags(host string) error {
+	all, err := readAllHostTags()
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, tags := range all {
+		for _, tag := range tags {
+			known[tag] = true
+		}
+	}
+
+	current := "-"
+	if len(all[host]) > 0 {
+		current = strings.Join(all[host], ", ")
+	}
+	fmt.Printf("Tags of %s: %s\n", host, current)
+	if len(known) > 0 {
+		fmt.Printf("Known tags: %s\n", strings.Join(slices.Sorted(maps.Keys(known)), ", "))
+	}
+	fmt.Print("\nNew tags, separated by spaces or commas (- removes them all, Enter keeps them): ")
+
+	var input string
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() {
+		input = strings.TrimSpace(scanner.Text())
+	}
+
+	switch input {
+	case "":
+		return errGoBack
+	case "-":
+		return writeHostTags(host, nil)
+	}
+	return writeHostTags(host, parseTags(input))
+}
+
+// splitQuery takes the tag:name filters out of the search query, the rest of
+// it is matched as before.
+func splitQuery(query string) (string, []string) {
+	var words, tags []string
+	for _, word := range strings.Fields(query) {
+		if len(word) >= 4 && strings.EqualFold(word[:4], "tag:") {
+			if tag := strings.ToLower(word[4:]); tag != "" {
+				tags = append(tags, tag)
+			}
+			continue
+		}
+		words = append(words, word)
+	}
+
+	if len(tags) == 0 {
+		return query, nil
+	}
+	return strings.Join(words, " "), tags
+}
+
+// hasTags tells if the host has all the tags.
+func hasTags(alias string, tags []string) bool {
+	if alias == "" {
+		return false
+	}
+	for _, tag := range tags {
+		if !slices.Contains(hostTags[alias], tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// readSmartFolders returns the queries of the smart folders by name.
+func readSmartFolders() (map[string]string, error) {
+	folders := map[string]string{}
+
+	rows, err := db.Query("SELECT name, query FROM smart_folders")
+	if err != nil {
+		return folders, fmt.Errorf("error querying the smart folders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, query string
+		if err := rows.Scan(&name, &query); err != nil {
+			return folders, fmt.Errorf("error scanning the smart folders: %w", err)
+		}
+		folders[name] = query
+	}
+
+	return folders, rows.Err()
+}
+
+// isSmartFolder tells if the folder shown by InitUi is a smart folder, those
+// are the smartFolderIcon followed by their name.
+func isSmartFolder(folder string) bool {
+	return strings.HasPrefix(folder, smartFolderIcon+" ")
+}
+
+// smartFolderName returns the name of a smart folder, or of its line in the list.
+func smartFolderName(folder string) string {
+	return strings.TrimPrefix(stripAnsi(folder), smartFolderIcon+" ")
+}
+
+// smartFolderLines returns the lines of the smart folders in the main list.
+func smartFolderLines() []string {
+	folders, err := readSmartFolders()
+	if err != nil {
+		log.Println(err)
+	}
+
+	var lines []string
+	for _, name := range slices.Sorted(maps.Keys(folders)) {
+		lines = append(lines, fmt.Sprintf("%s %s%s%s", smartFolderIcon, magenta, name, reset))
+	}
+	return lines
+}
+
+// smartFolderHosts returns the host lines of every folder that match the
+// query, best first.
+func (s *AllConfigs) smartFolderHosts(query string) []string {
+	hosts, _ := rankChoices(s.hostLines(), query, func(choice string) string { return choice }, false)
+	return hosts
+}
+
+// smartFolderItems returns the list of the smart folder.
+func (s *AllConfigs) smartFolderItems(folder string) []string {
+	folders, err := readSmartFolders()
+	if err != nil {
+		log.Println(err)
+	}
+
+	items := []string{}
+	if query, ok := folders[smartFolderName(folder)]; ok {
+		items = s.smartFolderHosts(query)
+	}
+	return append(items, removeSmartFolderItem, goback)
+}
+
+// saveSmartFolder asks for the name of a smart folder showing the hosts that
+// match the query, and returns it.
+func saveSmartFolder(query string) (string, error) {
+	fmt.Printf("Save the search %s%s%s as the smart folder: ", blue, query, reset)
+
+	var name string
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() {
+		name = strings.TrimSpace(scanner.Text())
+	}
+
+	if name == "" {
+		return "", errGoBack
+	}
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("a smart folder can't have a / in its name")
+	}
+
+	folders, err := readSmartFolders()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := folders[name]; ok {
+		return "", fmt.Errorf("a smart folder named %s already exists", name)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin the db transaction for the smart folder:%w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO smart_folders(name,query) VALUES(?,?);", name, query); err != nil {
+		return "", fmt.Errorf("failed to save the smart folder %s: %w", name, err)
+	}
+
+	return name, tx.Commit()
+}
+
+// removeSmartFolder removes the smart folder, the hosts stay where they are.
+func removeSmartFolder(name string) error {
+	answer, err := main_ui([]string{"Yes", "No"}, fmt.Sprintf("Remove the smart folder %s? Its hosts are kept.\n\n", name), false)
+	if err != nil || answer != "Yes" {
+		return errGoBack
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the db transaction for the smart folder:%w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM smart_folders WHERE name = ?;", name); err != nil {
+		return fmt.Errorf("failed to remove the smart folder %s: %w", name, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+const historyTimeFormat = "2006-01-02 15:04:05"
+
+type transferRecord struct {
+	time      time.Time
+	host      string
+	direction string // get, put or copy (between two remote panes)
+	source    string
+	target    string
+	bytes     int64
+	duration  time.Duration
+	checksum  string
+	result    string
+}
+
+// remotePath writes the path the way scp and sshcli get/put do, host:path.
+func (fs *FileSystem) remotePath(p string) string {
+	if fs.isRemote {
+		return fs.hostId + ":" + p
+	}
+	return p
+}
+
+// logTransfer stores a finished (or skipped) transfer in the transfers table.
+func logTransfer(sourceFS, targetFS *FileSystem, sourcePath, targetPath string, bytes int64, start time.Time, checksum, result string) {
+	r := transferRecord{
+		time:      start,
+		source:    sourceFS.remotePath(sourcePath),
+		target:    targetFS.remotePath(targetPath),
+		bytes:     bytes,
+		duration:  time.Since(start),
+		checksum:  checksum,
+		result:    result,
+		direction: "copy",
+		host:      sourceFS.hostId,
+	}
+
+	switch {
+	case sourceFS.isRemote && !targetFS.isRemote:
+		r.direction = "get"
+	case !sourceFS.isRemote && targetFS.isRemote:
+		r.direction = "put"
+		r.host = targetFS.hostId
+	}
+
+	if err := insertTransfer(r); err != nil {
+		log.Println(err)
+	}
+}
+
+func insertTransfer(r transferRecord) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the db transaction for the transfer history:%w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO transfers(time,host,direction,source,target,bytes,duration_ms,checksum,result) VALUES(?,?,?,?,?,?,?,?,?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare the transfer history statement: %w", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(r.time.Format(historyTimeFormat), r.host, r.direction, r.source, r.target, r.bytes, r.duration.Milliseconds(), r.checksum, r.result)
+	if err != nil {
+		return fmt.Errorf("failed to store the transfer of %s: %w", r.source, err)
+	}
+
+	return tx.Commit()
+}
+
+// readTransfers returns the newest transfers first. host matches the remote
+// side of the transfer, since and until are dates (YYYY-MM-DD) and both ends
+// are included. Empty filters match everything.
+func readTransfers(host, since, until string, limit int) ([]transferRecord, error) {
+	query := "SELECT time,host,direction,source,target,bytes,duration_ms,checksum,result FROM transfers WHERE 1=1"
+	var args []any
+
+	if host != "" {
+		// The target starts with host: when it's a remote path, matched as
+		// text since LIKE would take _ and % in the alias as wildcards
+		query += " AND (host = ? OR substr(target, 1, length(?)+1) = ? || ':')"
+		args = append(args, host, host, host)
+	}
+
+	if since != "" {
+		if _, err := time.Parse(time.DateOnly, since); err != nil {
+			return nil, fmt.Errorf("invalid date %q, use YYYY-MM-DD", since)
+		}
+		query += " AND time >= ?"
+		args = append(args, since)
+	}
+
+	if until != "" {
+		day, err := time.Parse(time.DateOnly, until)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, use YYYY-MM-DD", until)
+		}
+		query += " AND time < ?"
+		args = append(args, day.AddDate(0, 0, 1).Format(time.DateOnly))
+	}
+
+	query += " ORDER BY time DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying the transfer history: %w", err)
+	}
+	defer rows.Close()
+
+	var records []transferRecord
+	for rows.Next() {
+		var (
+			r          transferRecord
+			t          string
+			durationMs int64
+		)
+		if err := rows.Scan(&t, &r.host, &r.direction, &r.source, &r.target, &r.bytes, &durationMs, &r.checksum, &r.result); err != nil {
+			log.Println("Error scanning transfer:", err)
+			continue
+		}
+		r.time, _ = time.ParseInLocation(historyTimeFormat, t, time.Local)
+		r.duration = time.Duration(durationMs) * time.Millisecond
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+func (r transferRecord) line(checksumLen int) string {
+	checksum := r.checksum
+	if len(checksum) > checksumLen {
+		checksum = checksum[:checksumLen]
+	}
+
+	return fmt.Sprintf("%s  %-4s  %7s  %7s  %s → %s  %s  %s",
+		r.time.Format(historyTimeFormat), r.direction, formatSize(r.bytes), r.duration.Round(100*time.Millisecond),
+		r.source, r.target, r.result, checksum)
+}
+
+// printTransfers is the -transfers listing of the command line.
+func printTransfers(host, since, until string) error {
+	records, err := readTransfers(host, since, until, -1)
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No transfers found.")
+		return nil
+	}
+
+	for _, r := range records {
+		fmt.Println(r.line(64))
+	}
+
+	return nil
+}
+
+// showTransferHistory lists the latest transfers in the SFTP TUI.
+func showTransferHistory(app *tview.Application, pages *tview.Pages, done func()) {
+	records, err := readTransfers("", "", "", 500)
+	if err != nil {
+		log.Println(err)
+	}
+
+	var s strings.Builder
+	if err != nil {
+		fmt.Fprintf(&s, "[red]%v[white]\n", err)
+	} else if len(records) == 0 {
+		s.WriteString("No transfers yet.\n")
+	}
+
+	for _, r := range records {
+		color := "white"
+		switch {
+		case strings.HasPrefix(r.result, "failed"):
+			color = "red"
+		case strings.HasPrefix(r.result, "skipped"):
+			color = "yellow"
+		}
+		fmt.Fprintf(&s, "[%s]%s[white]\n", color, tview.Escape(r.line(12)))
+	}
+
+	showTextPage(app, pages, "history", " Transfer history, latest 500 (Esc to go back) ", s.String(), done)
+}
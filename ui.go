@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -15,6 +19,7 @@ func getSubMenuContent() []string {
 		fmt.Sprintf("%s(w)%s Open in Browser", yellow, reset),
 		fmt.Sprintf("%s(o)%s sftp (os native)", yellow, reset),
 		fmt.Sprintf("%s(t)%s %ssftp (text UI)%s", yellow, reset, BOLD, reset),
+		fmt.Sprintf("%s(T)%s sftp (text UI, sudo)", yellow, reset),
 		fmt.Sprintf("%s(i)%s %sping%s", yellow, reset, BOLD, reset),
 		fmt.Sprintf("%s(c)%s %stcping%s", yellow, reset, BOLD, reset),
 		fmt.Sprintf("%s(k)%s ssh-copy-id", yellow, reset),
@@ -25,6 +30,7 @@ func getSubMenuContent() []string {
 		fmt.Sprintf("%s(5)%s Set Socks Tunnel", yellow, reset),
 		fmt.Sprintf("%s(u)%s Set URL", yellow, reset),
 		fmt.Sprintf("%s(f)%s Set Folder", yellow, reset),
+		fmt.Sprintf("%s(g)%s Set Tags", yellow, reset),
 		fmt.Sprintf("%s(8)%s Set sshkey passphrase", yellow, reset),
 		fmt.Sprintf("%s(n)%s %sNotes%s", yellow, reset, BOLD, reset),
 		fmt.Sprintf("%s(r)%s Reveal Password", yellow, reset),
@@ -43,17 +49,18 @@ func (m *baseModel) filterChoices() {
 	if m.searchQuery == "" || !m.inSearchMode {
 		m.choices = make([]string, len(m.allChoices))
 		copy(m.choices, m.allChoices)
+		m.matches = nil
 		return
 	}
 
-	m.choices = nil
-	query := strings.ToLower(m.searchQuery)
-	for _, choice := range m.allChoices {
-		cleanChoice := strings.ReplaceAll(strings.ReplaceAll(choice, yellow, ""), reset, "")
-		if strings.Contains(strings.ToLower(cleanChoice), query) {
-			m.choices = append(m.choices, choice)
-		}
+	candidates := m.allChoices
+	if _, tags := splitQuery(m.searchQuery); m.searchAll || (m.hostList && len(tags) > 0) {
+		// Hosts of the other folders are found too, tags cross the folders
+		candidates = append(slices.Clone(m.allChoices), otherHostLines(m.allChoices)...)
+		m.maskWidth = max(m.maskWidth, maskWidth(candidates))
 	}
+
+	m.choices, m.matches = rankChoices(candidates, m.searchQuery, m.display, m.searchAll)
 }
 
 // SSH shortcut handlers
@@ -65,6 +72,7 @@ func (m *baseModel) handleSSHShortcuts(key string) (tea.Model, tea.Cmd) {
 		"n": fmt.Sprintf("%s(n)%s Notes", yellow, reset),
 		"p": fmt.Sprintf("%s(p)%s Set Password", yellow, reset),
 		"t": fmt.Sprintf("%s(t)%s sftp (text UI)", yellow, reset),
+		"T": fmt.Sprintf("%s(T)%s sftp (text UI, sudo)", yellow, reset),
 		"o": fmt.Sprintf("%s(o)%s sftp (os native)", yellow, reset),
 		"i": fmt.Sprintf("%s(i)%s ping", yellow, reset),
 		"c": fmt.Sprintf("%s(c)%s tcping", yellow, reset),
@@ -78,6 +86,7 @@ func (m *baseModel) handleSSHShortcuts(key string) (tea.Model, tea.Cmd) {
 		"u": fmt.Sprintf("%s(u)%s Set URL", yellow, reset),
 		"X": fmt.Sprintf("%s(X)%s Remove SSH Tunnel", yellow, reset),
 		"f": fmt.Sprintf("%s(f)%s Set Folder", yellow, reset),
+		"g": fmt.Sprintf("%s(g)%s Set Tags", yellow, reset),
 		"k": fmt.Sprintf("%s(k)%s ssh-copy-id", yellow, reset),
 		"8": fmt.Sprintf("%s(8)%s Set sshkey passphrase", yellow, reset),
 		"*": fmt.Sprintf("%s(*)%s Remove sshkey passphrase", yellow, reset),
@@ -115,8 +124,18 @@ func (m *baseModel) handleProfileShortcuts(key string) (tea.Model, tea.Cmd) {
 // Common update logic
 func (m *baseModel) updateBase(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+
 	case tea.KeyMsg:
-		switch msg.String() {
+		key := msg.String()
+
+		// Everything printable goes into the search query, even q, / and space
+		if m.inSearchMode && (msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace) {
+			key = ""
+		}
+
+		switch key {
 
 		case "/":
 			if !m.inSearchMode {
@@ -162,6 +181,68 @@ func (m *baseModel) updateBase(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return main_model{*m}, nil
 
+		case "ctrl+a":
+			// Selects the hosts shown, or unselects them if they all are
+			if !m.isSSHContext && m.hasHosts {
+				all := true
+				for _, choice := range m.choices {
+					if alias := hostAlias(choice); alias != "" && !m.selected[alias] {
+						all = false
+					}
+				}
+				for _, choice := range m.choices {
+					if alias := hostAlias(choice); alias != "" {
+						if all {
+							delete(m.selected, alias)
+						} else {
+							m.selected[alias] = true
+						}
+					}
+				}
+			}
+
+		case "a":
+			if !m.isSSHContext && m.hasHosts && len(m.selected) > 0 && len(m.choices) > 0 {
+				m.choice = m.choices[m.cursor]
+				m.bulk = true
+				m.inSearchMode = false
+				m.searchQuery = ""
+				m.filterChoices()
+				return main_model{*m}, tea.Quit
+			}
+
+		case "v":
+			if m.hasHosts && !m.isSSHContext {
+				m.preview = !m.preview
+				if err := writeSetting(fmt.Sprintf("preview=%t", m.preview)); err != nil {
+					log.Println(err)
+				}
+			}
+
+		case "f":
+			// Pins the host under the cursor to the favorites, or unpins it
+			if m.hostList && len(m.choices) > 0 && hostAlias(m.choices[m.cursor]) != "" {
+				m.choice = toggleFavoritePrefix + m.choices[m.cursor]
+				return main_model{*m}, tea.Quit
+			}
+
+		case "ctrl+s":
+			// Saves the search of the host list as a smart folder
+			if m.inSearchMode && m.hostList && strings.TrimSpace(m.searchQuery) != "" {
+				m.choice = saveSearchPrefix + strings.TrimSpace(m.searchQuery)
+				m.inSearchMode = false
+				m.searchQuery = ""
+				m.filterChoices()
+				return main_model{*m}, tea.Quit
+			}
+
+		case "tab":
+			if m.inSearchMode && !m.isSSHContext && searchHosts != nil {
+				m.searchAll = !m.searchAll
+				m.filterChoices()
+				m.cursor = 0
+			}
+
 		case "up":
 			if m.cursor > 0 {
 				m.cursor--
@@ -172,7 +253,36 @@ func (m *baseModel) updateBase(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.cursor++
 			}
 
-		case "enter", " ":
+		case "pgup":
+			m.cursor = max(0, m.cursor-m.listRows())
+
+		case "pgdown":
+			m.cursor = max(0, min(len(m.choices)-1, m.cursor+m.listRows()))
+
+		case "home":
+			m.cursor = 0
+
+		case "end":
+			m.cursor = max(0, len(m.choices)-1)
+
+		case " ":
+			// Space selects in the host list, it's Enter in the other menus
+			if !m.isSSHContext && m.hasHosts && len(m.choices) > 0 {
+				if alias := hostAlias(m.choices[m.cursor]); alias != "" {
+					if m.selected[alias] {
+						delete(m.selected, alias)
+					} else {
+						m.selected[alias] = true
+					}
+				}
+				if m.cursor < len(m.choices)-1 {
+					m.cursor++
+				}
+				break
+			}
+			fallthrough
+
+		case "enter":
 			if len(m.choices) > 0 {
 				m.choice = m.choices[m.cursor]
 				m.searchQuery = ""
@@ -189,19 +299,23 @@ func (m *baseModel) updateBase(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.Type {
 			case tea.KeyBackspace:
 				if len(m.searchQuery) > 0 {
-					m.searchQuery = m.searchQuery[:len(m.searchQuery)-1]
+					query := []rune(m.searchQuery)
+					m.searchQuery = string(query[:len(query)-1])
 					m.filterChoices()
-					if m.cursor >= len(m.choices) {
-						m.cursor = max(0, len(m.choices)-1)
+					m.cursor = 0
+				}
+			case tea.KeyRunes, tea.KeySpace:
+				typed := ""
+				for _, r := range msg.Runes {
+					if unicode.IsPrint(r) {
+						typed += string(r)
 					}
 				}
-			case tea.KeyRunes:
-				if len(msg.Runes) == 1 && (unicode.IsLetter(msg.Runes[0]) || unicode.IsDigit(msg.Runes[0])) {
-					m.searchQuery += string(msg.Runes)
+				if typed != "" {
+					m.searchQuery += typed
 					m.filterChoices()
-					if m.cursor >= len(m.choices) {
-						m.cursor = max(0, len(m.choices)-1)
-					}
+					// The best match is on top
+					m.cursor = 0
 				}
 			}
 		} else if m.isSSHContext {
@@ -211,9 +325,16 @@ func (m *baseModel) updateBase(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handleProfileShortcuts(msg.String())
 		}
 	}
-	// Handle mouse clicks (detect double-click)
+	// Handle the mouse wheel and clicks (detect double-click)
 	switch msg := msg.(type) {
 	case tea.MouseMsg:
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonWheelUp {
+			m.scroll(-3)
+		}
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonWheelDown {
+			m.scroll(3)
+		}
+
 		// Only handle left button *release* clicks to avoid treating
 		// the press+release of a single click as a double-click.
 		if msg.Button == tea.MouseButtonLeft && msg.Type == tea.MouseRelease {
@@ -232,29 +353,11 @@ func (m *baseModel) updateBase(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return main_model{*m}, tea.Quit
 				}
 			} else {
-				// Single click: update cursor based on clicked row (best-effort)
-				// Map terminal Y coordinate to displayed choice index.
-				// This is a simple heuristic; adjust if your header/message layout differs.
-
-				// compute number of lines before choices start
-				headerLines := -1
-				if m.isSSHContext {
-					// leading blank + instruction + blank line
-					headerLines += 4
-				}
-				if m.inSearchMode {
-					// "Search mode: <query>" + blank line
-					headerLines += 3
-				} else if !m.isSSHContext {
-					// account for message lines (if any)
-					if m.message != "" {
-						headerLines += len(strings.Split(m.message, "\n"))
-					}
-				}
-
-				clicked := msg.Y - headerLines
-				if clicked >= 0 && clicked < len(m.choices) {
-					m.cursor = clicked
+				// Single click: move the cursor to the clicked row. The rows
+				// start below the header and show the choices from the offset on.
+				clicked := msg.Y - strings.Count(m.header(), "\n")
+				if clicked >= 0 && clicked < m.listRows() && m.offset+clicked < len(m.choices) {
+					m.cursor = m.offset + clicked
 				} else {
 					// clamp cursor
 					if m.cursor >= len(m.choices) {
@@ -273,47 +376,227 @@ func (m *baseModel) updateBase(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return main_model{*m}, nil
 }
 
-// Common view logic
-func (m *baseModel) viewBase() string {
+// maskWidth is the width of the longest alias of the host lines, secure mode
+// pads the aliases to it.
+func maskWidth(items []string) int {
+	width := -1
+	for _, item := range items {
+		item = cleanTheString(item, "onlyColors")
+		if strings.Contains(item, sshIcon) && !strings.Contains(item, "New SSH Profile") {
+			width = max(width, len(strings.Split(item, " ")[1]))
+		}
+	}
+	return width
+}
+
+// display returns the choice the way it's shown. In secure mode the user and
+// address of the hosts are hidden, only the alias and the flags are left.
+func (m *baseModel) display(choice string) string {
+	if !isSecure || m.isSSHContext {
+		return choice
+	}
+
+	chosen := cleanTheString(choice, "onlyColors")
+	if !strings.Contains(chosen, sshIcon) || strings.Contains(chosen, "New SSH Profile") {
+		return chosen
+	}
+
+	alias := strings.Split(chosen, " ")[1]
+	return private + " " + alias + strings.Repeat(" ", max(0, m.maskWidth-len(alias))) + " " + chosen[strings.Index(chosen, "("):]
+}
+
+// highlightChoice marks the runes the search matched. A match on a field
+// that isn't on the line, like the URL, is shown after it.
+func (m *baseModel) highlightChoice(choice string) string {
+	line := m.display(choice)
+	match, ok := m.matches[choice]
+	if !ok {
+		return line
+	}
 
+	if match.onLine {
+		return highlight(line, match.positions)
+	}
+
+	text, positions := snippet(match.field.text, match.positions, 60)
+	return fmt.Sprintf("%s  %s%s:%s %s", line, blue, match.field.name, reset, highlight(text, positions))
+}
+
+// header is what's printed above the choices.
+func (m *baseModel) header() string {
 	var s strings.Builder
 	if m.isSSHContext {
 		s.WriteString(fmt.Sprintf("\n%sPress shortcut key, / to search, arrows+Enter to select, or q to quit.%s\n\n", yellow, reset))
 	}
 
 	if m.inSearchMode {
-		s.WriteString(fmt.Sprintf("Search mode: %s\n\n", m.searchQuery))
+		scope := ""
+		if !m.isSSHContext && searchHosts != nil {
+			scope = fmt.Sprintf("  %s(Tab: search names only)%s", yellow, reset)
+			if !m.searchAll {
+				scope = fmt.Sprintf("  %s(Tab: search notes, URLs and settings of all folders too)%s", yellow, reset)
+			}
+		}
+		s.WriteString(fmt.Sprintf("Search mode: %s%s\n\n", m.searchQuery, scope))
 	} else if !m.isSSHContext {
 		s.WriteString(m.message)
 	}
 
+	return s.String()
+}
+
+// footer is what's printed below the choices: the preview pane and the
+// help line.
+func (m *baseModel) footer() string {
+	if m.isSSHContext {
+		return ""
+	}
+
+	help := "Press shortcut key, / to search, arrows+Enter to select, or q to quit."
+	if m.hasHosts {
+		help = "Press shortcut key, / to search, v to toggle the preview, f to (un)pin a favorite, space to select hosts, arrows+Enter to select, or q to quit."
+	}
+	if m.inSearchMode && m.hostList {
+		help = "Type to search, tag:name keeps the hosts with the tag, ctrl+s saves the search as a smart folder, Esc to leave the search."
+	}
+	if len(m.selected) > 0 {
+		help = fmt.Sprintf("%d selected: a for the bulk actions, space to (un)select, ctrl+a to (un)select all shown, / to search, or q to quit.", len(m.selected))
+	}
+	return fmt.Sprintf("\n%s%s%s%s\n", m.previewPane(), yellow, help, reset)
+}
+
+// previewRows is the height of the preview pane. It's left out when the
+// terminal is too small to show it next to a useful part of the list.
+func (m *baseModel) previewRows() int {
+	if !m.preview || !m.hasHosts || m.isSSHContext || m.height < 20 {
+		return 0
+	}
+	return min(14, m.height*2/5)
+}
+
+// previewPane shows the entry under the cursor, always on previewRows lines
+// so the list doesn't jump when the cursor moves.
+func (m *baseModel) previewPane() string {
+	rows := m.previewRows()
+	if rows == 0 {
+		return ""
+	}
+
+	var lines []string
+	if m.cursor < len(m.choices) {
+		lines = previewLines(m.choices[m.cursor])
+	}
+	if len(lines) > rows-1 {
+		lines = append(lines[:rows-2:rows-2], "…")
+	}
+
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("%s%s%s\n", blue, strings.Repeat("─", 60), reset))
+	for i := range rows - 1 {
+		if i < len(lines) {
+			s.WriteString(lines[i])
+		}
+		s.WriteString("\n")
+	}
+	s.WriteString("\n")
+
+	return s.String()
+}
+
+// listRows is how many choices fit on the screen between the header and the
+// footer. All of them do until the height of the terminal is known.
+func (m *baseModel) listRows() int {
+	if m.height <= 0 {
+		return max(1, len(m.choices))
+	}
+
+	// The view ends with a newline, the empty line after it takes a row too
+	rows := m.height - 1 - strings.Count(m.header(), "\n") - strings.Count(m.footer(), "\n")
+	if len(m.choices) > rows {
+		// One more row for the position indicator
+		rows--
+	}
+	return max(1, rows)
+}
+
+// scrollToCursor moves the viewport so the cursor is on the screen.
+func (m *baseModel) scrollToCursor() {
+	rows := m.listRows()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+	m.offset = max(0, min(m.offset, len(m.choices)-rows))
+}
+
+// scroll moves the viewport by the given number of rows, the cursor stays on
+// the screen.
+func (m *baseModel) scroll(by int) {
+	rows := m.listRows()
+	m.offset = max(0, min(m.offset+by, len(m.choices)-rows))
+	m.cursor = max(m.offset, min(m.cursor, m.offset+rows-1, len(m.choices)-1))
+}
+
+// Common view logic
+func (m *baseModel) viewBase() string {
+
+	var s strings.Builder
+	s.WriteString(m.header())
+
 	if len(m.choices) == 0 {
 		s.WriteString("No matches found.\n")
 	} else {
-		for i, choice := range m.choices {
+		rows := m.listRows()
+		end := min(len(m.choices), m.offset+rows)
+		for i := m.offset; i < end; i++ {
 			cursor := " "
 			if m.cursor == i {
 				cursor = fmt.Sprintf(" %s>%s", green, reset)
 			}
-			s.WriteString(fmt.Sprintf("%s %s\n", cursor, choice))
+			mark := " "
+			if m.selected[hostAlias(m.choices[i])] {
+				mark = fmt.Sprintf("%s✓%s", green, reset)
+			}
+			s.WriteString(fmt.Sprintf("%s%s%s\n", cursor, mark, m.highlightChoice(m.choices[i])))
 		}
-	}
 
-	if !m.isSSHContext {
-		s.WriteString(fmt.Sprintf("\n%sPress shortcut key, / to search, arrows+Enter to select, or q to quit.%s\n", yellow, reset))
+		if len(m.choices) > rows {
+			up, down := " ", " "
+			if m.offset > 0 {
+				up = "↑"
+			}
+			if end < len(m.choices) {
+				down = "↓"
+			}
+			s.WriteString(fmt.Sprintf("%s  %s %d-%d of %d %s  (PgUp/PgDn, Home/End or the mouse wheel to scroll)%s\n", blue, up, m.offset+1, end, len(m.choices), down, reset))
+		}
 	}
 
+	s.WriteString(m.footer())
+
 	return s.String()
 }
 
 // Interface methods for ssh_model
 func (m ssh_model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	return m.baseModel.updateBase(msg)
+	model, cmd := m.baseModel.updateBase(msg)
+	if updated, ok := model.(ssh_model); ok {
+		updated.scrollToCursor()
+		return updated, cmd
+	}
+	return model, cmd
 }
 
 // Interface methods for main_model
 func (m main_model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	return m.baseModel.updateBase(msg)
+	model, cmd := m.baseModel.updateBase(msg)
+	if updated, ok := model.(main_model); ok {
+		updated.scrollToCursor()
+		return updated, cmd
+	}
+	return model, cmd
 }
 
 func (m ssh_model) View() string {
@@ -332,38 +615,46 @@ func (m main_model) Init() tea.Cmd {
 	return nil
 }
 
+// errQuit is returned by the menus when the user quits them.
+var errQuit = errors.New(`
+         (o o)
+   --oOO--(_)--OOo--
+    Have a nice day!
+	`)
+
+// menuKey identifies an entry of the host list across menus: the alias of a
+// host or the name of a folder.
+func menuKey(item string) string {
+	item = cleanTheString(item, "all")
+	if alias, _, ok := strings.Cut(item, " >"); ok {
+		return strings.TrimSpace(alias)
+	}
+	return item
+}
+
 func main_ui(items []string, message string, isSshContextMenu bool) (string, error) {
+	chosen, _, err := main_ui_cursor(items, message, isSshContextMenu, false, "")
+	return chosen, err
+}
 
-	var p *tea.Program
+// main_ui_at shows the host list with the cursor on the entry with the given
+// menuKey, if it's still there. When the bulk actions are asked for, the
+// selected hosts are returned too. A search saved with ctrl+s is returned as
+// the saveSearchPrefix followed by the query.
+func main_ui_at(items []string, message, cursorOn string) (string, []string, error) {
+	return main_ui_cursor(items, message, false, true, cursorOn)
+}
 
-	var secureItems []string
-	if isSecure {
-		maxlen := -1
-		for _, chosen := range items {
-			chosen = cleanTheString(chosen, "onlyColors")
-			if strings.Contains(chosen, sshIcon) {
-				if !strings.Contains(chosen, "New SSH Profile") {
-					chosenParts := strings.Split(chosen, " ")
-					if len(chosenParts[1]) > maxlen {
-						maxlen = len(chosenParts[1])
-					}
-				}
-			}
-		}
+func main_ui_cursor(items []string, message string, isSshContextMenu, hostList bool, cursorOn string) (string, []string, error) {
 
-		for _, chosen := range items {
-			newItem := ""
-			chosen = cleanTheString(chosen, "onlyColors")
-			if strings.Contains(chosen, sshIcon) {
-				if !strings.Contains(chosen, "New SSH Profile") {
-					chosenParts := strings.Split(chosen, " ")
-					newItem = private + " " + chosenParts[1] + strings.Repeat(" ", maxlen-len(chosenParts[1])) + " " + chosen[strings.Index(chosen, "("):]
-					secureItems = append(secureItems, newItem)
-				} else {
-					secureItems = append(secureItems, chosen)
-				}
-			} else {
-				secureItems = append(secureItems, chosen)
+	var p *tea.Program
+
+	cursor := 0
+	if cursorOn != "" {
+		for i, item := range items {
+			if menuKey(item) == cursorOn {
+				cursor = i
+				break
 			}
 		}
 	}
@@ -373,66 +664,45 @@ func main_ui(items []string, message string, isSshContextMenu bool) (string, err
 			baseModel: baseModel{
 				allChoices:   getSubMenuContent(),
 				choices:      getSubMenuContent(),
-				selected:     make(map[int]string),
+				selected:     map[string]bool{},
 				isSSHContext: true,
 			},
 		}, tea.WithAltScreen(), tea.WithMouseAllMotion())
 	} else {
-
-		if isSecure {
-			p = tea.NewProgram(main_model{
-				baseModel: baseModel{
-					allChoices:   secureItems,
-					choices:      secureItems,
-					selected:     make(map[int]string),
-					message:      message,
-					isSSHContext: false,
-				},
-			}, tea.WithAltScreen(), tea.WithMouseAllMotion())
-		} else {
-			p = tea.NewProgram(main_model{
-				baseModel: baseModel{
-					allChoices:   items,
-					choices:      items,
-					selected:     make(map[int]string),
-					message:      message,
-					isSSHContext: false,
-				},
-			}, tea.WithAltScreen(), tea.WithMouseAllMotion())
-		}
+		p = tea.NewProgram(main_model{
+			baseModel: baseModel{
+				allChoices:   items,
+				choices:      items,
+				selected:     map[string]bool{},
+				message:      message,
+				isSSHContext: false,
+				maskWidth:    maskWidth(items),
+				cursor:       cursor,
+				hostList:     hostList,
+				preview:      readBoolSetting("preview"),
+				hasHosts:     slices.ContainsFunc(items, func(item string) bool { return hostAlias(item) != "" }),
+			},
+		}, tea.WithAltScreen(), tea.WithMouseAllMotion())
 	}
 
 	finalModel, err := p.Run()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if isSshContextMenu {
 		if m, ok := finalModel.(ssh_model); ok && m.choice != "" {
-			return m.choice, nil
+			return m.choice, nil, nil
 		}
 	} else {
-
 		if m, ok := finalModel.(main_model); ok && m.choice != "" {
-			if isSecure {
-				itemIndex := 0
-
-				for i, j := range secureItems {
-					if j == m.choice {
-						itemIndex = i
-					}
-				}
-				return items[itemIndex], nil
-
-			} else {
-				return m.choice, nil
+			if m.bulk {
+				selected := slices.Sorted(maps.Keys(m.selected))
+				return m.choice, selected, nil
 			}
+			return m.choice, nil, nil
 		}
 	}
 
-	return "", fmt.Errorf(`
-         (o o)
-   --oOO--(_)--OOo--
-    Have a nice day!
-	`)
+	return "", nil, errQuit
 }